@@ -2,8 +2,9 @@ package atomlayer
 
 import (
 	"bytes"
+	"encoding/binary"
+	"io"
 	"github.com/golang/protobuf/proto"
-	"fmt"
 )
 
 // The atomlayer is the lowest-level representation used by the tracing plane.  It represents a BaggageContext using
@@ -16,13 +17,26 @@ import (
 
 type Atom []byte
 
-// Merges two BaggageContexts by lexicographically comparing their atoms
+// Merges two BaggageContexts by lexicographically comparing their atoms.  If the atoms of one context are a prefix of
+// the other's, as is common when joining a branch that made no changes, then the longer is returned without copying.
+// Only a shared prefix is skipped: once the inputs diverge, the remainder of both is merged atom by atom into a new
+// slice, so merging branches that diverge early still takes time linear in their size, not in their differences.
 func Merge(a, b []Atom) []Atom {
 	if a == nil && b == nil { return nil }
-	merged := make([]Atom, 0, len(a)+len(b))
-	i, j := 0, 0
+
+	// Skip the prefix shared by both inputs
+	shared := 0
+	for shared < len(a) && shared < len(b) && equal(a[shared], b[shared]) { shared++ }
+	switch {
+	case shared == len(b) && a != nil: return clip(a)
+	case shared == len(a): return clip(b)
+	}
+
+	merged := make([]Atom, shared, len(a)+len(b))
+	copy(merged, a[:shared])
+	i, j := shared, shared
 	for i < len(a) && j < len(b) {
-		switch bytes.Compare(a[i], b[j]) {
+		switch compare(a[i], b[j]) {
 		case -1: merged = append(merged, a[i]); i++;
 		case 0: merged = append(merged, a[i]); i++; j++;
 		case 1: merged = append(merged, b[j]); j++;
@@ -33,9 +47,31 @@ func Merge(a, b []Atom) []Atom {
 	return merged
 }
 
-// Duplicates a BaggageContext
-func Branch(a []Atom) (b []Atom) {
-	return append(b, a...)
+// Duplicates a BaggageContext.  The duplicate shares the original's backing array, so this takes constant time
+// regardless of the size of the context.  This relies on callers never modifying the atoms of either in place.
+func Branch(a []Atom) []Atom {
+	return clip(a)
+}
+
+// Restricts the capacity of atoms to its length, so that appending to the returned slice cannot write into a backing
+// array that is shared with another context
+func clip(atoms []Atom) []Atom {
+	return atoms[:len(atoms):len(atoms)]
+}
+
+// Returns true if a and b are the same slice of bytes, which is the case for atoms that were shared by a Branch
+func same(a, b Atom) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// Compares atoms, only comparing their contents if they aren't the same slice of bytes
+func compare(a, b Atom) int {
+	if same(a, b) { return 0 }
+	return bytes.Compare(a, b)
+}
+
+func equal(a, b Atom) bool {
+	return same(a, b) || bytes.Equal(a, b)
 }
 
 // Returns the serialized size in bytes of this atom array.
@@ -49,61 +85,65 @@ func (atom Atom) serializedSize() int {
 	return proto.SizeVarint(uint64(len(atom))) + len(atom)
 }
 
-// Serializes the baggage context by varint-prefixing each atom.]
+// Serializes the baggage context by varint-prefixing each atom.
 func Serialize(atoms []Atom) []byte {
 	if len(atoms) == 0 { return nil }
-	length := SerializedSize(atoms)
-	serializedAtoms := make([]byte, 0, length)
+	return AppendSerialize(make([]byte, 0, SerializedSize(atoms)), atoms)
+}
+
+// Serializes the baggage context, appending it to dst and returning the extended slice.  If dst has sufficient
+// capacity (see SerializedSize) then no allocation takes place.
+func AppendSerialize(dst []byte, atoms []Atom) []byte {
 	for _, atom := range atoms {
-		serializedAtoms = append(serializedAtoms, proto.EncodeVarint(uint64(len(atom)))...)
-		serializedAtoms = append(serializedAtoms, atom...)
+		dst = binary.AppendUvarint(dst, uint64(len(atom)))
+		dst = append(dst, atom...)
 	}
-	return serializedAtoms
+	return dst
 }
 
-// Deserializes a baggage context from bytes
-func Deserialize(bytes []byte) (atoms []Atom, err error) {
-	pos := 0
-	for len(bytes) > 0 {
-		x, n := proto.DecodeVarint(bytes)
-		switch {
-		case n == 0 && len(bytes) > 10:  bytes = bytes[:10]; fallthrough
-		case n == 0: err = fmt.Errorf("Encountered at position %v invalid varint %v", pos, bytes); return
-		case n + int(x) > len(bytes): err = fmt.Errorf("Insufficient bytes remaining in buffer for %v-length atom at position %v", x, pos); return
-		default: {
-			bytes = bytes[n:]
-			atoms = append(atoms, Atom(bytes[:int(x)]))
-			bytes = bytes[int(x):]
-			pos += n + int(x)
-		}}
+// Serializes the baggage context directly to the provided writer.  Returns the number of bytes written and the first
+// error encountered.
+func WriteTo(w io.Writer, atoms []Atom) (n int64, err error) {
+	var prefix [binary.MaxVarintLen64]byte
+	for _, atom := range atoms {
+		var written int
+		written, err = w.Write(prefix[:binary.PutUvarint(prefix[:], uint64(len(atom)))])
+		if n += int64(written); err != nil { return }
+		written, err = w.Write(atom)
+		if n += int64(written); err != nil { return }
 	}
 	return
 }
 
+// Deserializes a baggage context from bytes produced by either Serialize or SerializeFrontCoded.  To deserialize atoms
+// lazily, use a Decoder instead.
+func Deserialize(bytes []byte) (atoms []Atom, err error) {
+	return NewDecoder(bytes).Remaining()
+}
+
 var TrimMarker = Atom(make([]byte, 0, 0)) // Special zero-length atom used to indicate trim
 
 func IsTrimMarker(a Atom) bool {
 	return bytes.Equal(TrimMarker, a)
 }
 
-// Drop atoms from the BaggageContext so that it fits into the specified number of bytes
+// Drop atoms from the BaggageContext so that it fits into the specified number of bytes.  If any atoms are dropped
+// then a TrimMarker is appended in their place, and the returned atoms, including the TrimMarker, are guaranteed to fit
+// within maxSize.  If maxSize is too small even for the TrimMarker then all atoms are dropped.
 func Trim(atoms []Atom, maxSize int) []Atom {
-	switch trimAt := indexForTrim(atoms, maxSize); {
-	case trimAt == len(atoms): return atoms
-	default: return append(atoms[:trimAt], TrimMarker)
+	markerSize := TrimMarker.serializedSize()
+	switch keep := FittingPrefix(atoms, maxSize - markerSize); {
+	case SerializedSize(atoms) <= maxSize: return atoms								// Already fits
+	case maxSize < markerSize: return atoms[:0:0]									// Not even the marker fits
+	case keep > 0 && IsTrimMarker(atoms[keep-1]): return atoms[:keep:keep]			// Marker is already in place
+	default: return append(atoms[:keep:keep], TrimMarker)
 	}
 }
 
-// Calculates the index at which to trim the baggage to fit in the specified size
-func indexForTrim(atoms []Atom, size int) int {
+// Returns the largest number of leading atoms whose combined serialized size is no more than size
+func FittingPrefix(atoms []Atom, size int) int {
 	for i, atom := range atoms {
-		switch atomSize := atom.serializedSize(); {
-		case atomSize < size: size -= atomSize;
-		case atomSize > size: return i
-		case i == len(atoms)-1: size -= atomSize;
-		default: return i
-		}
+		if size -= atom.serializedSize(); size < 0 { return i }
 	}
 	return len(atoms)
 }
-
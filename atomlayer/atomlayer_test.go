@@ -3,6 +3,10 @@ package atomlayer
 import (
 	"testing"
 	"github.com/stretchr/testify/assert"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing/quick"
 )
 
 func TestLexicographicMerge(t *testing.T) {
@@ -118,7 +122,7 @@ func TestTrim(t *testing.T) {
 	assert.Equal(t, []Atom{Atom{}}, 		 Trim([]Atom{Atom{1,2,3,4,5}}, 3))
 	assert.Equal(t, []Atom{Atom{}}, 		 Trim([]Atom{Atom{1,2,3,4,5}}, 2))
 	assert.Equal(t, []Atom{Atom{}}, 		 Trim([]Atom{Atom{1,2,3,4,5}}, 1))
-	assert.Equal(t, []Atom{Atom{}}, 		 Trim([]Atom{Atom{1,2,3,4,5}}, 0))
+	assert.Equal(t, []Atom{}, 		 		 Trim([]Atom{Atom{1,2,3,4,5}}, 0))
 	assert.Equal(t, []Atom(nil), 		 	 Trim(nil, 3))
 
 	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 10))
@@ -131,6 +135,137 @@ func TestTrim(t *testing.T) {
 	assert.Equal(t, []Atom{Atom{}}, 						Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 3))
 	assert.Equal(t, []Atom{Atom{}}, 						Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 2))
 	assert.Equal(t, []Atom{Atom{}}, 						Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 1))
-	assert.Equal(t, []Atom{Atom{}}, 						Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 0))
+	assert.Equal(t, []Atom{}, 								Trim([]Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, 0))
 
-}
\ No newline at end of file
+}
+
+func TestAppendSerialize(t *testing.T) {
+	atomContext := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{}, Atom{1}, Atom{1}}
+
+	assert.Equal(t, Serialize(atomContext), AppendSerialize(nil, atomContext))
+	assert.Equal(t, append([]byte{9, 9}, Serialize(atomContext)...), AppendSerialize([]byte{9, 9}, atomContext))
+
+	buf := make([]byte, 0, SerializedSize(atomContext))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { AppendSerialize(buf, atomContext) }))
+}
+
+func TestWriteTo(t *testing.T) {
+	atomContext := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{}, Atom{1}, Atom{1}}
+
+	var buf bytes.Buffer
+	n, err := WriteTo(&buf, atomContext)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), n)
+	assert.Equal(t, Serialize(atomContext), buf.Bytes())
+}
+
+func TestBranchSharesAtoms(t *testing.T) {
+	a := []Atom{Atom{1}, Atom{2}, Atom{3}}
+	b := Branch(a)
+	assert.Equal(t, a, b)
+	assert.Same(t, &a[0], &b[0])
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { Branch(a) }))
+
+	// Appending to a branch must not be visible to the other branch
+	a = make([]Atom, 3, 10)
+	a[0], a[1], a[2] = Atom{1}, Atom{2}, Atom{3}
+	b = Branch(a)
+	b = append(b, Atom{4})
+	c := append(Branch(a), Atom{5})
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{3}, Atom{4}}, b)
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{3}, Atom{5}}, c)
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{3}}, a)
+}
+
+func TestTrimDoesNotModifyBranches(t *testing.T) {
+	a := []Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}
+	b := Branch(a)
+	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, Atom{}}, Trim(b, 7))
+	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}}, a)
+}
+
+func TestMergeSharesUnchangedBranches(t *testing.T) {
+	a := []Atom{Atom{1}, Atom{2}, Atom{3}}
+	b := Branch(a)
+
+	// Merging a branch that made no changes returns the original atoms without copying
+	merged := Merge(a, b)
+	assert.Equal(t, a, merged)
+	assert.Same(t, &a[0], &merged[0])
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { Merge(a, b) }))
+
+	// Likewise if one branch only appended atoms
+	c := append(Branch(a), Atom{4})
+	merged = Merge(a, c)
+	assert.Equal(t, c, merged)
+	assert.Same(t, &c[0], &merged[0])
+	assert.Equal(t, c, Merge(c, a))
+
+	// Otherwise the shared prefix is retained and only the remainder is merged
+	d := append(Branch(a[:2]), Atom{2, 5})
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{2, 5}, Atom{3}}, Merge(a, d))
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{2, 5}, Atom{3}}, Merge(d, a))
+}
+
+// Random baggage for property-based tests, including some trim markers and some atoms long enough to need a
+// multi-byte length prefix
+type randomBaggage []Atom
+
+func (randomBaggage) Generate(r *rand.Rand, size int) reflect.Value {
+	atoms := make(randomBaggage, r.Intn(size+1))
+	for i := range atoms {
+		switch r.Intn(10) {
+		case 0: atoms[i] = TrimMarker
+		case 1: atoms[i] = make(Atom, 100 + r.Intn(200))
+		default: atoms[i] = make(Atom, 1 + r.Intn(10))
+		}
+		r.Read(atoms[i])
+	}
+	return reflect.ValueOf(atoms)
+}
+
+func TestTrimAlwaysFits(t *testing.T) {
+	property := func(atoms randomBaggage) bool {
+		for maxSize := 0; maxSize <= SerializedSize(atoms) + 2; maxSize++ {
+			if SerializedSize(Trim(atoms, maxSize)) > maxSize { return false }
+		}
+		return true
+	}
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 200}))
+}
+
+func TestTrimKeepsLongestPrefix(t *testing.T) {
+	property := func(atoms randomBaggage) bool {
+		for maxSize := 0; maxSize <= SerializedSize(atoms) + 2; maxSize++ {
+			trimmed := Trim(atoms, maxSize)
+			switch {
+			case SerializedSize(atoms) <= maxSize:
+				if !reflect.DeepEqual([]Atom(atoms), trimmed) { return false }
+			case maxSize == 0:
+				if len(trimmed) != 0 { return false }
+			default:
+				// The trimmed atoms end with a trim marker, preceded by a prefix of the original atoms
+				kept := len(trimmed) - 1
+				if !IsTrimMarker(trimmed[kept]) { return false }
+				if !reflect.DeepEqual([]Atom(atoms[:kept]), trimmed[:kept]) { return false }
+
+				// The prefix is as long as possible: keeping one more atom would exceed the budget
+				if !IsTrimMarker(atoms[kept]) && SerializedSize(atoms[:kept+1]) + 1 <= maxSize { return false }
+			}
+		}
+		return true
+	}
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 200}))
+}
+
+func TestFittingPrefix(t *testing.T) {
+	atoms := []Atom{Atom{1,2,3,4,5}, Atom{3, 2, 1}, Atom{}}
+	assert.Equal(t, 0, FittingPrefix(atoms, -1))
+	assert.Equal(t, 0, FittingPrefix(atoms, 5))
+	assert.Equal(t, 1, FittingPrefix(atoms, 6))
+	assert.Equal(t, 1, FittingPrefix(atoms, 9))
+	assert.Equal(t, 2, FittingPrefix(atoms, 10))
+	assert.Equal(t, 3, FittingPrefix(atoms, 11))
+	assert.Equal(t, 3, FittingPrefix(atoms, 100))
+	assert.Equal(t, 0, FittingPrefix(nil, 100))
+}
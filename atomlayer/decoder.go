@@ -0,0 +1,183 @@
+package atomlayer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"github.com/golang/protobuf/proto"
+)
+
+// A Decoder lazily deserializes atoms one at a time, rather than materializing the entire []Atom up front like
+// Deserialize does.  When decoding from a byte slice, the returned atoms are subslices of that byte slice, so no atom
+// bytes are copied.  When decoding from an io.Reader, each atom is read into its own freshly allocated slice.  Front-
+// coded input (see SerializeFrontCoded) is detected automatically, in which case each atom is reconstructed into its
+// own freshly allocated slice.
+type Decoder struct {
+	serialized []byte        // Remaining serialized bytes, if decoding from a byte slice
+	stream     *bufio.Reader // The underlying stream, if decoding from an io.Reader
+	next       Atom          // The next atom, if it has already been decoded by Peek
+	pos        int           // Number of bytes consumed so far
+	started    bool          // True once the format of the input has been detected
+	frontCoded bool          // True if the input is front coded
+	previous   Atom          // The previously decoded atom, if the input is front coded
+	Err        error
+}
+
+// Returns a Decoder over the provided serialized bytes
+func NewDecoder(serialized []byte) *Decoder {
+	return &Decoder{serialized: serialized}
+}
+
+// Returns a Decoder that reads serialized atoms from the provided io.Reader
+func NewStreamDecoder(r io.Reader) *Decoder {
+	if br, ok := r.(*bufio.Reader); ok {
+		return &Decoder{stream: br}
+	}
+	return &Decoder{stream: bufio.NewReader(r)}
+}
+
+// Returns the next atom without consuming it, or nil if there are no atoms remaining or an error occurred
+func (d *Decoder) Peek() Atom {
+	if d.next == nil && d.Err == nil {
+		d.next = d.decode()
+	}
+	return d.next
+}
+
+// Consumes and returns the next atom, or nil if there are no atoms remaining or an error occurred.  Note that a
+// TrimMarker is returned as a non-nil zero-length atom.
+func (d *Decoder) Next() Atom {
+	next := d.Peek()
+	d.next = nil
+	return next
+}
+
+// Advances the Decoder to the first atom that is lexicographically greater than or equal to target, without consuming
+// it.  This is typically used to seek to a root bag by its header atom.
+// Returns:
+// 		exists - true if the next atom is the target, false otherwise
+//		overflowed - true if a trim marker was skipped while seeking, false otherwise
+func (d *Decoder) Seek(target Atom) (exists bool, overflowed bool) {
+	for next := d.Peek(); next != nil; next = d.Peek() {
+		switch bytes.Compare(next, target) {
+		case -1: overflowed = overflowed || IsTrimMarker(next); d.Next()	// Haven't encountered yet
+		case 0:  return true, overflowed									// Found it
+		case 1:  return false, overflowed									// Went past it
+		}
+	}
+	return false, overflowed
+}
+
+// Decodes all remaining atoms.  If an error occurs, returns the atoms decoded before the error along with the error.
+func (d *Decoder) Remaining() (atoms []Atom, err error) {
+	for next := d.Next(); next != nil; next = d.Next() {
+		atoms = append(atoms, next)
+	}
+	return atoms, d.Err
+}
+
+// Returns an independent Decoder positioned at the same atom as this one, which can be used to look ahead.  Forking is
+// only possible when decoding from a byte slice; returns nil when decoding from an io.Reader.
+func (d *Decoder) Fork() *Decoder {
+	if d.stream != nil { return nil }
+	fork := *d
+	return &fork
+}
+
+// Returns the number of serialized bytes consumed so far, including any atom that has been peeked
+func (d *Decoder) Offset() int {
+	return d.pos
+}
+
+func (d *Decoder) decode() Atom {
+	if !d.started { d.detectFormat() }
+	switch {
+	case d.frontCoded:		return d.decodeFrontCoded()
+	case d.stream != nil:	return d.decodeStream()
+	}
+
+	if len(d.serialized) == 0 { return nil }
+	x, n := proto.DecodeVarint(d.serialized)
+	switch {
+	case n == 0 && len(d.serialized) > 10:	return d.seterror(invalidVarint(d.pos, d.serialized[:10]))
+	case n == 0:							return d.seterror(invalidVarint(d.pos, d.serialized))
+	case x > uint64(len(d.serialized)-n):	return d.seterror(insufficientBytes(x, d.pos))
+	}
+
+	atom := Atom(d.serialized[n:n+int(x)])
+	d.serialized = d.serialized[n+int(x):]
+	d.pos += n + int(x)
+	return atom
+}
+
+func (d *Decoder) decodeStream() Atom {
+	x, err := binary.ReadUvarint(d.stream)
+	n := proto.SizeVarint(x)
+	switch {
+	case err == io.EOF:						return nil
+	case err != nil:						return d.seterror(fmt.Errorf("Encountered at position %v invalid varint: %v", d.pos, err))
+	}
+
+	start := d.pos
+	d.pos += n
+	atom, ok := d.readStream(nil, x)
+	if !ok { return d.seterror(insufficientBytes(x, start)) }
+	return atom
+}
+
+// Largest piece of an atom that is read from a stream at once
+const streamChunkSize = 64 * 1024
+
+// Reads length bytes from the stream, returning them appended to a copy of prefix, or false if there are insufficient
+// bytes.  The length comes from the stream itself, so rather than allocating it up front, the atom grows one piece at a
+// time as its bytes arrive, and a bogus length fails with a short read instead of exhausting memory.
+func (d *Decoder) readStream(prefix []byte, length uint64) (Atom, bool) {
+	atom := append(make(Atom, 0, uint64(len(prefix)) + min(length, streamChunkSize)), prefix...)
+	for remaining := length; remaining > 0; {
+		chunk := min(remaining, streamChunkSize)
+		start := len(atom)
+		atom = append(atom, make([]byte, chunk)...)
+		if _, err := io.ReadFull(d.stream, atom[start:]); err != nil { return nil, false }
+		remaining -= chunk
+	}
+	d.pos += int(length)
+	return atom, true
+}
+
+// Checks for, and consumes, the front-coded header
+func (d *Decoder) detectFormat() {
+	d.started = true
+	switch {
+	case d.stream == nil:	d.frontCoded = IsFrontCoded(d.serialized)
+	default:				header, _ := d.stream.Peek(2); d.frontCoded = IsFrontCoded(header)
+	}
+	if d.frontCoded {
+		if version := d.header(); version != frontCodedVersion { d.seterror(unsupportedVersion(version)) }
+	}
+}
+
+// Consumes the two-byte front-coded header, returning its version
+func (d *Decoder) header() int {
+	var header [2]byte
+	d.read(header[:])
+	return int(header[0] & 0x7f)
+}
+
+func (d *Decoder) seterror(err error) Atom {
+	d.Err = err
+	return nil
+}
+
+func unsupportedVersion(version int) error {
+	return fmt.Errorf("Unsupported serialization format version %v", version)
+}
+
+func invalidVarint(pos int, bytes []byte) error {
+	return fmt.Errorf("Encountered at position %v invalid varint %v", pos, bytes)
+}
+
+func insufficientBytes(length uint64, pos int) error {
+	return fmt.Errorf("Insufficient bytes remaining in buffer for %v-length atom at position %v", length, pos)
+}
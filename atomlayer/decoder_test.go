@@ -0,0 +1,118 @@
+package atomlayer
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"bytes"
+	"encoding/binary"
+)
+
+func TestDecoderEmpty(t *testing.T) {
+	d := NewDecoder(nil)
+	assert.Nil(t, d.Peek())
+	assert.Nil(t, d.Next())
+	assert.Nil(t, d.Err)
+
+	d = NewStreamDecoder(bytes.NewReader(nil))
+	assert.Nil(t, d.Next())
+	assert.Nil(t, d.Err)
+}
+
+func TestDecoderIsZeroCopy(t *testing.T) {
+	serialized := Serialize([]Atom{Atom{1,2,3}, Atom{}, Atom{4}})
+	d := NewDecoder(serialized)
+
+	first := d.Next()
+	assert.Equal(t, Atom{1,2,3}, first)
+	assert.Same(t, &serialized[1], &first[0])
+
+	marker := d.Next()
+	assert.NotNil(t, marker)
+	assert.True(t, IsTrimMarker(marker))
+
+	assert.Equal(t, Atom{4}, d.Peek())
+	assert.Equal(t, Atom{4}, d.Next())
+	assert.Nil(t, d.Next())
+	assert.Nil(t, d.Err)
+	assert.Equal(t, len(serialized), d.Offset())
+}
+
+func TestDecoderMatchesDeserialize(t *testing.T) {
+	atoms := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{}, Atom{1}, Atom{1}}
+	serialized := Serialize(atoms)
+
+	decoded, err := NewDecoder(serialized).Remaining()
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, decoded)
+
+	decoded, err = NewStreamDecoder(bytes.NewReader(serialized)).Remaining()
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, decoded)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	atoms := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{}, Atom{1}, Atom{1}}
+	serialized := Serialize(atoms)
+
+	decoded, err := NewDecoder(serialized[:14]).Remaining()
+	assert.NotNil(t, err)
+	assert.Equal(t, atoms[:4], decoded)
+
+	decoded, err = NewStreamDecoder(bytes.NewReader(serialized[:14])).Remaining()
+	assert.NotNil(t, err)
+	assert.Equal(t, atoms[:4], decoded)
+
+	d := NewDecoder([]byte{255})
+	assert.Nil(t, d.Next())
+	assert.NotNil(t, d.Err)
+
+	d = NewStreamDecoder(bytes.NewReader([]byte{255}))
+	assert.Nil(t, d.Next())
+	assert.NotNil(t, d.Err)
+}
+
+func TestDecoderSeek(t *testing.T) {
+	atoms := []Atom{Atom{1}, Atom{}, Atom{5}, Atom{8}, Atom{9}}
+	serialized := Serialize(atoms)
+
+	d := NewDecoder(serialized)
+	exists, overflowed := d.Seek(Atom{5})
+	assert.True(t, exists)
+	assert.True(t, overflowed)
+	assert.Equal(t, Atom{5}, d.Next())
+
+	exists, overflowed = d.Seek(Atom{6})
+	assert.False(t, exists)
+	assert.False(t, overflowed)
+	assert.Equal(t, Atom{8}, d.Peek())
+
+	exists, overflowed = d.Seek(Atom{10})
+	assert.False(t, exists)
+	assert.Nil(t, d.Next())
+}
+
+func TestDecoderFork(t *testing.T) {
+	d := NewDecoder(Serialize([]Atom{Atom{1}, Atom{2}, Atom{3}}))
+	d.Next()
+	fork := d.Fork()
+	assert.Equal(t, Atom{2}, fork.Next())
+	assert.Equal(t, Atom{3}, fork.Next())
+	assert.Equal(t, Atom{2}, d.Next())
+
+	assert.Nil(t, NewStreamDecoder(bytes.NewReader(nil)).Fork())
+}
+
+func TestDecoderStreamBogusLength(t *testing.T) {
+	for _, length := range []uint64{1 << 62, 1 << 40, 1 << 20} {
+		serialized := append(binary.AppendUvarint(nil, length), 1, 2, 3)
+		d := NewStreamDecoder(bytes.NewReader(serialized))
+		assert.Nil(t, d.Next())
+		assert.NotNil(t, d.Err)
+	}
+
+	// Atoms larger than a single piece are read in full
+	atoms := []Atom{bytes.Repeat([]byte{7}, 3 * streamChunkSize + 5), Atom{}, Atom{1}}
+	decoded, err := NewStreamDecoder(bytes.NewReader(Serialize(atoms))).Remaining()
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, decoded)
+}
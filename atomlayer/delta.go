@@ -0,0 +1,248 @@
+package atomlayer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// This file contains delta encoding between contexts.  When a context is sent somewhere and comes back, or is
+// repeatedly sent along a long-lived stream, most of its atoms are usually unchanged.  Rather than sending the entire
+// context each time, Diff computes the changes between the previously sent atoms and the current atoms, and the
+// receiver, which still has the previous atoms, uses Apply to reconstruct the current atoms.
+//
+// A Delta records a fingerprint of the atoms it was computed from, so that Apply fails rather than silently producing
+// the wrong context if the receiver's atoms differ from the sender's.
+
+// The changes needed to transform one slice of atoms into another
+type Delta struct {
+	Base  uint64 // Fingerprint of the atoms that the delta applies to
+	Hunks []Hunk // The changes, in order of increasing, non-overlapping offset
+}
+
+// Replaces a contiguous run of atoms
+type Hunk struct {
+	Offset  int    // Position in the base atoms of the first atom to remove, or where to insert if none are removed
+	Removed int    // Number of atoms to remove
+	Added   []Atom // Atoms to insert in place of the removed atoms
+}
+
+// Beyond this many edits, Diff gives up on finding the smallest delta and replaces everything that differs
+const maxDiffEdits = 1024
+
+// Computes the delta that transforms from into to.  Atoms that are common to both are never included in the delta.
+func Diff(from, to []Atom) Delta {
+	delta := Delta{Base: Fingerprint(from)}
+
+	// Most changes are localized, so skip the common prefix and suffix before doing any real work
+	start := 0
+	for start < len(from) && start < len(to) && equal(from[start], to[start]) { start++ }
+	endFrom, endTo := len(from), len(to)
+	for endFrom > start && endTo > start && equal(from[endFrom-1], to[endTo-1]) { endFrom--; endTo-- }
+
+	delta.Hunks = diff(from[start:endFrom], to[start:endTo], start)
+	return delta
+}
+
+// Returns true if the delta makes no changes
+func (delta Delta) Empty() bool {
+	return len(delta.Hunks) == 0
+}
+
+// Applies the delta to the provided atoms, which must be the atoms the delta was computed from.  The provided atoms
+// are not modified.
+func (delta Delta) Apply(atoms []Atom) ([]Atom, error) {
+	if fingerprint := Fingerprint(atoms); fingerprint != delta.Base { return nil, deltaBaseMismatch(delta.Base, fingerprint) }
+	if delta.Empty() { return clip(atoms), nil }
+
+	// Check every hunk before sizing the result.  Written so that huge counts can't overflow
+	size, pos := len(atoms), 0
+	for i, hunk := range delta.Hunks {
+		if hunk.Offset < pos || hunk.Removed < 0 || hunk.Removed > len(atoms)-hunk.Offset { return nil, invalidHunk(i, hunk, len(atoms)) }
+		size += len(hunk.Added) - hunk.Removed
+		pos = hunk.Offset + hunk.Removed
+	}
+
+	applied := make([]Atom, 0, size)
+	pos = 0
+	for _, hunk := range delta.Hunks {
+		applied = append(applied, atoms[pos:hunk.Offset]...)
+		applied = append(applied, hunk.Added...)
+		pos = hunk.Offset + hunk.Removed
+	}
+	return append(applied, atoms[pos:]...), nil
+}
+
+// Computes a 64-bit FNV-1a hash of the serialized representation of the atoms
+func Fingerprint(atoms []Atom) uint64 {
+	h := fnv.New64a()
+	var length [binary.MaxVarintLen64]byte
+	for _, atom := range atoms {
+		h.Write(length[:binary.PutUvarint(length[:], uint64(len(atom)))])
+		h.Write(atom)
+	}
+	return h.Sum64()
+}
+
+// Finds the hunks that transform a into b using Myers' O(ND) difference algorithm.  Hunk offsets are shifted by offset.
+func diff(a, b []Atom, offset int) []Hunk {
+	switch {
+	case len(a) == 0 && len(b) == 0: return nil
+	case len(a) == 0 || len(b) == 0: return []Hunk{{offset, len(a), clip(b)}}
+	}
+
+	// Find the furthest reaching path for each diagonal k = x - y, for increasing numbers of edits d.  trace[d] holds
+	// the endpoints for diagonals -d to d after d edits
+	n, m := len(a), len(b)
+	v := make([]int, 2*(n+m)+3)
+	at := func(k int) *int { return &v[k+n+m+1] }
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits { return []Hunk{{offset, n, clip(b)}} }
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case k == -d || (k != d && *at(k-1) < *at(k+1)):	x = *at(k+1)		// Insert an atom of b
+			default:											x = *at(k-1) + 1	// Remove an atom of a
+			}
+			for y := x - k; x < n && y < m && equal(a[x], b[y]); y++ { x++ }
+			*at(k) = x
+		}
+		trace = append(trace, append([]int(nil), v[n+m+1-d:n+m+1+d+1]...))
+		if *at(n-m) >= n { break }
+	}
+
+	// Backtrack from the end, recording the atoms that are common to both.  Each edit is followed by a run of matches
+	type match struct{ x, y int }
+	matches := []match{{n, m}}
+	x, y := n, m
+	for d := len(trace)-1; d > 0; d-- {
+		prev := func(k int) int { return trace[d-1][k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) { prevK = k + 1 }
+		prevX, prevY := prev(prevK), prev(prevK) - prevK
+
+		startX, startY := prevX + 1, prevY			// Removed an atom of a
+		if prevK == k+1 { startX, startY = prevX, prevY + 1 }	// Inserted an atom of b
+		for x > startX && y > startY { x--; y--; matches = append(matches, match{x, y}) }
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 { x--; y--; matches = append(matches, match{x, y}) }
+	matches = append(matches, match{-1, -1})
+
+	// Every gap between consecutive matches is a hunk
+	var hunks []Hunk
+	for i := len(matches)-1; i > 0; i-- {
+		from, to := matches[i], matches[i-1]
+		if to.x > from.x+1 || to.y > from.y+1 {
+			hunks = append(hunks, Hunk{offset + from.x + 1, to.x - from.x - 1, b[from.y+1:to.y:to.y]})
+		}
+	}
+	return hunks
+}
+
+// Serializes a delta.  The format is the 8-byte base fingerprint followed by the number of hunks, then for each hunk
+// the number of unchanged atoms preceding it, the number of atoms removed, and the number of atoms added, followed by
+// the added atoms in the same format as Serialize.  All numbers are varints.
+func SerializeDelta(delta Delta) []byte {
+	return AppendSerializeDelta(nil, delta)
+}
+
+// Serializes a delta, appending it to dst and returning the extended slice
+func AppendSerializeDelta(dst []byte, delta Delta) []byte {
+	dst = binary.BigEndian.AppendUint64(dst, delta.Base)
+	dst = binary.AppendUvarint(dst, uint64(len(delta.Hunks)))
+	pos := 0
+	for _, hunk := range delta.Hunks {
+		dst = binary.AppendUvarint(dst, uint64(hunk.Offset - pos))
+		dst = binary.AppendUvarint(dst, uint64(hunk.Removed))
+		dst = binary.AppendUvarint(dst, uint64(len(hunk.Added)))
+		dst = AppendSerialize(dst, hunk.Added)
+		pos = hunk.Offset + hunk.Removed
+	}
+	return dst
+}
+
+// Deserializes a delta that was serialized with SerializeDelta.  As with Deserialize, the added atoms are subslices
+// of the provided bytes.
+func DeserializeDelta(serialized []byte) (delta Delta, err error) {
+	if len(serialized) < 8 { return delta, truncatedDelta(len(serialized)) }
+	delta.Base = binary.BigEndian.Uint64(serialized)
+	d := deltaDecoder{serialized: serialized[8:], pos: 8}
+
+	count := d.uvarint()
+	if d.err == nil && count > uint64(len(d.serialized))/3 { return delta, truncatedDelta(len(serialized)) }	// Each hunk takes at least 3 bytes
+	delta.Hunks = make([]Hunk, 0, count)
+	pos := uint64(0)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		unchanged, removed, added := d.uvarint(), d.uvarint(), d.uvarint()
+		switch {
+		case d.err != nil:
+		case unchanged > maxDeltaCount-pos || removed > maxDeltaCount-pos-unchanged:	return delta, invalidHunkCounts(int(i), unchanged, removed)	// Offsets must fit in an int
+		case added > uint64(len(d.serialized)):											return delta, truncatedDelta(len(serialized))	// Each atom takes at least 1 byte
+		}
+		hunk := Hunk{Offset: int(pos + unchanged), Removed: int(removed), Added: make([]Atom, 0, added)}
+		for j := uint64(0); j < added && d.err == nil; j++ {
+			hunk.Added = append(hunk.Added, d.atom())
+		}
+		delta.Hunks = append(delta.Hunks, hunk)
+		pos += unchanged + removed
+	}
+
+	switch {
+	case d.err != nil:				return delta, d.err
+	case len(d.serialized) > 0:		return delta, trailingDeltaBytes(len(d.serialized), d.pos)
+	default:						return delta, nil
+	}
+}
+
+// The largest offset of an atom in a serialized delta
+const maxDeltaCount = uint64(math.MaxInt)
+
+// Decodes the varints and atoms of a serialized delta, remembering the first error
+type deltaDecoder struct {
+	serialized []byte
+	pos        int
+	err        error
+}
+
+func (d *deltaDecoder) uvarint() uint64 {
+	if d.err != nil { return 0 }
+	x, n := binary.Uvarint(d.serialized)
+	if n <= 0 { d.err = invalidVarint(d.pos, d.serialized[:min(len(d.serialized), binary.MaxVarintLen64)]); return 0 }
+	d.serialized = d.serialized[n:]
+	d.pos += n
+	return x
+}
+
+func (d *deltaDecoder) atom() Atom {
+	length := d.uvarint()
+	if d.err != nil { return nil }
+	if length > uint64(len(d.serialized)) { d.err = insufficientBytes(length, d.pos); return nil }
+	atom := Atom(d.serialized[:length:length])
+	d.serialized = d.serialized[length:]
+	d.pos += int(length)
+	return atom
+}
+
+func deltaBaseMismatch(expected, actual uint64) error {
+	return fmt.Errorf("Delta applies to atoms with fingerprint %x but atoms have fingerprint %x", expected, actual)
+}
+
+func invalidHunk(i int, hunk Hunk, length int) error {
+	return fmt.Errorf("Hunk %v removing %v atoms at offset %v is out of order or out of range for %v atoms", i, hunk.Removed, hunk.Offset, length)
+}
+
+func invalidHunkCounts(i int, unchanged, removed uint64) error {
+	return fmt.Errorf("Hunk %v keeping %v atoms and removing %v atoms is out of range", i, unchanged, removed)
+}
+
+func truncatedDelta(length int) error {
+	return fmt.Errorf("Serialized delta of %v bytes is truncated", length)
+}
+
+func trailingDeltaBytes(count, pos int) error {
+	return fmt.Errorf("Encountered %v unexpected bytes at position %v following serialized delta", count, pos)
+}
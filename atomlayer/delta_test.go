@@ -0,0 +1,179 @@
+package atomlayer
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing/quick"
+	"encoding/binary"
+	"math"
+)
+
+func TestDiffUnchanged(t *testing.T) {
+	atoms := []Atom{Atom{1}, Atom{2}, Atom{3}}
+	delta := Diff(atoms, atoms)
+	assert.True(t, delta.Empty())
+
+	applied, err := delta.Apply(atoms)
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, applied)
+}
+
+func TestDiffHunks(t *testing.T) {
+	from := []Atom{Atom{1}, Atom{2}, Atom{3}, Atom{4}, Atom{5}}
+	to := []Atom{Atom{1}, Atom{3}, Atom{4}, Atom{7}, Atom{8}, Atom{5}, Atom{9}}
+
+	delta := Diff(from, to)
+	assert.Equal(t, Fingerprint(from), delta.Base)
+	assert.Equal(t, []Hunk{
+		Hunk{1, 1, []Atom{}},
+		Hunk{4, 0, []Atom{Atom{7}, Atom{8}}},
+		Hunk{5, 0, []Atom{Atom{9}}},
+	}, delta.Hunks)
+
+	applied, err := delta.Apply(from)
+	assert.Nil(t, err)
+	assert.Equal(t, to, applied)
+}
+
+func TestDiffEmpty(t *testing.T) {
+	atoms := []Atom{Atom{1}, Atom{}}
+
+	applied, err := Diff(nil, atoms).Apply(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, applied)
+
+	applied, err = Diff(atoms, nil).Apply(atoms)
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestApplyWrongBase(t *testing.T) {
+	from := []Atom{Atom{1}, Atom{2}}
+	delta := Diff(from, []Atom{Atom{1}, Atom{3}})
+
+	_, err := delta.Apply([]Atom{Atom{1}, Atom{4}})
+	assert.NotNil(t, err)
+
+	delta.Hunks[0].Offset = 5
+	_, err = delta.Apply(from)
+	assert.NotNil(t, err)
+}
+
+func TestApplyDoesNotModifyBase(t *testing.T) {
+	from := append(make([]Atom, 0, 4), Atom{1}, Atom{2}, Atom{3})
+	applied, err := Diff(from, []Atom{Atom{1}, Atom{5}, Atom{3}}).Apply(from)
+	assert.Nil(t, err)
+	assert.Equal(t, []Atom{Atom{1}, Atom{5}, Atom{3}}, applied)
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, Atom{3}}, from)
+}
+
+func TestSerializeDelta(t *testing.T) {
+	from := []Atom{Atom{1}, Atom{2}, Atom{3}, Atom{4}, Atom{5}}
+	to := []Atom{Atom{1}, Atom{3}, Atom{4}, Atom{}, Atom{8, 8, 8}, Atom{5}}
+	delta := Diff(from, to)
+
+	serialized := SerializeDelta(delta)
+
+	deserialized, err := DeserializeDelta(serialized)
+	assert.Nil(t, err)
+	assert.Equal(t, delta.Base, deserialized.Base)
+
+	applied, err := deserialized.Apply(from)
+	assert.Nil(t, err)
+	assert.Equal(t, to, applied)
+
+	for i := 0; i < len(serialized); i++ {
+		_, err = DeserializeDelta(serialized[:i])
+		assert.NotNil(t, err)
+	}
+	_, err = DeserializeDelta(append(serialized, 0))
+	assert.NotNil(t, err)
+}
+
+// Atoms from a small alphabet, so that diffs have plenty of matches
+func similarAtoms(r *rand.Rand, length int) []Atom {
+	atoms := make([]Atom, length)
+	for i := range atoms {
+		atoms[i] = Atom{byte(r.Intn(4))}
+	}
+	return atoms
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		from, to := similarAtoms(r, r.Intn(50)), similarAtoms(r, r.Intn(50))
+
+		delta, err := DeserializeDelta(SerializeDelta(Diff(from, to)))
+		if err != nil { return false }
+		applied, err := delta.Apply(from)
+		if err != nil || len(applied) != len(to) { return false }
+		for i := range to {
+			if !equal(applied[i], to[i]) { return false }
+		}
+
+		// The number of atoms removed and added never exceeds the total
+		changed := 0
+		for _, hunk := range delta.Hunks { changed += hunk.Removed + len(hunk.Added) }
+		return changed <= len(from) + len(to)
+	}
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 500}))
+}
+
+func TestDiffSmallChange(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	from := similarAtoms(r, 1000)
+	to := append(append(append([]Atom(nil), from[:400]...), Atom{9}), from[420:]...)
+
+	delta := Diff(from, to)
+	assert.Equal(t, 1, len(delta.Hunks))
+	assert.Equal(t, 20, delta.Hunks[0].Removed)
+	assert.Equal(t, []Atom{Atom{9}}, delta.Hunks[0].Added)
+	assert.True(t, len(SerializeDelta(delta)) < SerializedSize(to)/100)
+}
+
+func TestDiffManyChanges(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	from, to := similarAtoms(r, 3000), similarAtoms(r, 3000)
+
+	applied, err := Diff(from, to).Apply(from)
+	assert.Nil(t, err)
+	assert.Equal(t, to, applied)
+}
+
+func TestApplyMalformed(t *testing.T) {
+	base := []Atom{Atom{1}, Atom{2}, Atom{3}}
+	fingerprint := Fingerprint(base)
+	for _, hunks := range [][]Hunk{
+		{{Offset: 1, Removed: math.MaxInt}},
+		{{Offset: math.MaxInt, Removed: math.MaxInt}},
+		{{Offset: 4}},
+		{{Offset: 1, Removed: -1}},
+		{{Offset: 2, Removed: 1}, {Offset: 1}},
+		{{Offset: 1, Removed: 3}},
+	} {
+		_, err := Delta{fingerprint, hunks}.Apply(base)
+		assert.NotNil(t, err)
+	}
+
+	// A serialized delta whose counts overflow an int is rejected when deserialized
+	serialized := binary.BigEndian.AppendUint64(nil, fingerprint)
+	for _, x := range []uint64{1, 1, 1 << 63 - 1, 0} { serialized = binary.AppendUvarint(serialized, x) }
+	assert.Equal(t, 20, len(serialized))
+	_, err := DeserializeDelta(serialized)
+	assert.NotNil(t, err)
+
+	serialized = binary.BigEndian.AppendUint64(nil, fingerprint)
+	for _, x := range []uint64{2, 1 << 62, 0, 0, 1 << 62, 0, 0} { serialized = binary.AppendUvarint(serialized, x) }
+	_, err = DeserializeDelta(serialized)
+	assert.NotNil(t, err)
+
+	// Counts that are in range for an int but not for the base are rejected when applied
+	serialized = binary.BigEndian.AppendUint64(nil, fingerprint)
+	for _, x := range []uint64{1, 1, 1 << 40, 0} { serialized = binary.AppendUvarint(serialized, x) }
+	delta, err := DeserializeDelta(serialized)
+	assert.Nil(t, err)
+	_, err = delta.Apply(base)
+	assert.NotNil(t, err)
+}
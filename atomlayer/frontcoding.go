@@ -0,0 +1,144 @@
+package atomlayer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"github.com/golang/protobuf/proto"
+)
+
+// This file contains an alternative, front-coded serialization of atoms.  Since atoms are kept in lexicographic order,
+// consecutive atoms often share a long prefix -- for example, the header atoms of sibling bags, or the data atoms of a
+// set.  Front coding writes each atom as the length of the prefix it shares with the previous atom and the length of
+// the remaining suffix, followed by the suffix bytes themselves.  Both lengths are packed into a single varint: the
+// low 3 bits hold the shared length, or 7 if the shared length is 7 or more, in which case a second varint holds the
+// remainder.  The remaining bits hold the suffix length, so suffixes of up to 15 bytes need only a single length byte.
+//
+// Front-coded atoms begin with a two-byte header, 0x80|version followed by 0x00.  Read as a varint, this is a non-
+// minimal encoding of an atom length, which Serialize never produces, so Deserialize and Decoder can tell the two
+// formats apart and accept either.
+
+const frontCodedVersion = 1
+const maxPackedShared = 7
+
+// Serializes the baggage context by front coding each atom.  The result can be deserialized with Deserialize.
+func SerializeFrontCoded(atoms []Atom) []byte {
+	if len(atoms) == 0 { return nil }
+	return AppendSerializeFrontCoded(make([]byte, 0, FrontCodedSize(atoms)), atoms)
+}
+
+// Front codes the baggage context, appending it to dst and returning the extended slice.  If dst has sufficient
+// capacity (see FrontCodedSize) then no allocation takes place.
+func AppendSerializeFrontCoded(dst []byte, atoms []Atom) []byte {
+	dst = append(dst, 0x80 | frontCodedVersion, 0x00)
+	var previous Atom
+	for _, atom := range atoms {
+		shared := sharedPrefix(previous, atom)
+		dst = binary.AppendUvarint(dst, uint64(len(atom) - shared) << 3 | uint64(min(shared, maxPackedShared)))
+		if shared >= maxPackedShared { dst = binary.AppendUvarint(dst, uint64(shared - maxPackedShared)) }
+		dst = append(dst, atom[shared:]...)
+		previous = atom
+	}
+	return dst
+}
+
+// Returns the front-coded size in bytes of this atom array
+func FrontCodedSize(atoms []Atom) int {
+	if len(atoms) == 0 { return 0 }
+	size := 2
+	var previous Atom
+	for _, atom := range atoms {
+		shared := sharedPrefix(previous, atom)
+		size += proto.SizeVarint(uint64(len(atom) - shared) << 3 | uint64(min(shared, maxPackedShared))) + len(atom) - shared
+		if shared >= maxPackedShared { size += proto.SizeVarint(uint64(shared - maxPackedShared)) }
+		previous = atom
+	}
+	return size
+}
+
+// Returns true if the serialized bytes begin with the front-coded header
+func IsFrontCoded(serialized []byte) bool {
+	return len(serialized) >= 2 && serialized[0] & 0x80 != 0 && serialized[1] == 0x00
+}
+
+func sharedPrefix(a, b Atom) (i int) {
+	for i < len(a) && i < len(b) && a[i] == b[i] { i++ }
+	return
+}
+
+// Decodes the next front-coded atom.  Unlike the varint format, the atom is reconstructed into a new slice.
+func (d *Decoder) decodeFrontCoded() Atom {
+	start := d.pos
+	packed, n := d.uvarint()
+	switch {
+	case n == 0:								return nil													// End of input
+	case n < 0:									return d.seterror(invalidFrontCoding(start))
+	}
+
+	length, shared := packed >> 3, packed & maxPackedShared
+	if shared == maxPackedShared {
+		excess, n := d.uvarint()
+		if n <= 0 { return d.seterror(invalidFrontCoding(start)) }
+		shared += excess
+	}
+
+	switch {
+	case shared > uint64(len(d.previous)):		return d.seterror(invalidSharedPrefix(shared, len(d.previous), start))
+	case d.stream == nil && length > uint64(len(d.serialized)):	return d.seterror(insufficientBytes(length, start))
+	}
+
+	// As in decodeStream, the length from a stream can't be trusted until its bytes arrive
+	var atom Atom
+	switch {
+	case d.stream != nil:
+		var ok bool
+		if atom, ok = d.readStream(d.previous[:shared], length); !ok { return d.seterror(insufficientBytes(length, start)) }
+	default:
+		atom = make(Atom, shared + length)
+		copy(atom, d.previous[:shared])
+		d.read(atom[shared:])
+	}
+	d.previous = atom
+	return atom
+}
+
+// Reads a varint from the input.  Returns the number of bytes read, which is 0 at the end of input and negative if the
+// varint is malformed or truncated.
+func (d *Decoder) uvarint() (uint64, int) {
+	if d.stream == nil {
+		if len(d.serialized) == 0 { return 0, 0 }
+		x, n := binary.Uvarint(d.serialized)
+		if n <= 0 { return 0, -1 }
+		d.serialized = d.serialized[n:]
+		d.pos += n
+		return x, n
+	}
+
+	if _, err := d.stream.Peek(1); err != nil { return 0, 0 }
+	x, err := binary.ReadUvarint(d.stream)
+	if err != nil { return 0, -1 }
+	n := proto.SizeVarint(x)
+	d.pos += n
+	return x, n
+}
+
+// Fills dst from the input.  Returns false if there are insufficient bytes remaining.
+func (d *Decoder) read(dst []byte) bool {
+	if d.stream == nil {
+		if len(dst) > len(d.serialized) { return false }
+		copy(dst, d.serialized)
+		d.serialized = d.serialized[len(dst):]
+	} else if _, err := io.ReadFull(d.stream, dst); err != nil {
+		return false
+	}
+	d.pos += len(dst)
+	return true
+}
+
+func invalidFrontCoding(pos int) error {
+	return fmt.Errorf("Encountered at position %v invalid or truncated front-coded atom", pos)
+}
+
+func invalidSharedPrefix(shared uint64, previous int, pos int) error {
+	return fmt.Errorf("Encountered at position %v front-coded atom sharing %v bytes with %v-length previous atom", pos, shared, previous)
+}
@@ -0,0 +1,168 @@
+package atomlayer
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing/quick"
+)
+
+func zipkinAtoms() []Atom {
+	return []Atom{
+		{248,2},
+		{240,0},
+		{0,0,0,0,0,0,0,0,55},
+		{240,1},
+		{0,0,0,0,0,0,0,0,70},
+		{240,2},
+		{0,0,0,0,0,0,0,0,10},
+	}
+}
+
+// A bag containing a set of sorted 8-byte IDs, like the parent event IDs of X-Trace
+func setAtoms(r *rand.Rand, n int) []Atom {
+	atoms := make([]Atom, n)
+	for i := range atoms {
+		atoms[i] = make(Atom, 9)
+		r.Read(atoms[i][1:])
+	}
+	return append([]Atom{{248, 5}, {240, 1}}, sortAtoms(atoms)...)
+}
+
+func TestFrontCoded(t *testing.T) {
+	atoms := []Atom{{248,2}, {240,0}, {240,0,1}, {}, {240,1}, {1,2,3}}
+	serialized := SerializeFrontCoded(atoms)
+	assert.True(t, IsFrontCoded(serialized))
+	assert.False(t, IsFrontCoded(Serialize(atoms)))
+	assert.Equal(t, FrontCodedSize(atoms), len(serialized))
+	assert.Equal(t, []byte{0x81, 0, 0x10, 248, 2, 0x10, 240, 0, 0x0a, 1, 0x00, 0x10, 240, 1, 0x18, 1, 2, 3}, serialized)
+
+	deserialized, err := Deserialize(serialized)
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, deserialized)
+
+	deserialized, err = NewStreamDecoder(bytes.NewReader(serialized)).Remaining()
+	assert.Nil(t, err)
+	assert.Equal(t, atoms, deserialized)
+
+	assert.Nil(t, SerializeFrontCoded(nil))
+}
+
+func TestFrontCodedRoundTrip(t *testing.T) {
+	property := func(atoms randomBaggage) bool {
+		deserialized, err := Deserialize(SerializeFrontCoded(atoms))
+		if err != nil || len(deserialized) != len(atoms) { return false }
+		for i := range atoms {
+			if !bytes.Equal(atoms[i], deserialized[i]) { return false }
+		}
+		return true
+	}
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 200}))
+}
+
+func TestFrontCodedErrors(t *testing.T) {
+	atoms := []Atom{{240,0}, {240,0,1}, {}, {240,1}, bytes.Repeat([]byte{7}, 20), bytes.Repeat([]byte{7}, 21)}
+	serialized := SerializeFrontCoded(atoms)
+
+	// Truncating between atoms yields a prefix of the atoms; truncating mid-atom is an error
+	for i := 2; i < len(serialized); i++ {
+		decoded, err := Deserialize(serialized[:i])
+		if err == nil { assert.Equal(t, atoms[:len(decoded)], append([]Atom{}, decoded...)) }
+		streamed, streamErr := NewStreamDecoder(bytes.NewReader(serialized[:i])).Remaining()
+		assert.Equal(t, err == nil, streamErr == nil)
+		assert.Equal(t, decoded, streamed)
+	}
+	_, err := Deserialize(serialized[:len(serialized)-1])
+	assert.NotNil(t, err)
+
+	// Shares more bytes than the previous atom has
+	_, err = Deserialize([]byte{0x81, 0, 0x08, 5, 0x0a, 0})
+	assert.NotNil(t, err)
+
+	// Unknown version
+	_, err = Deserialize([]byte{0x82, 0, 0x08, 5})
+	assert.NotNil(t, err)
+
+	// Lengths far beyond the input fail rather than being allocated
+	for _, length := range []uint64{1 << 60, 1 << 40, 1 << 20} {
+		bogus := append(binary.AppendUvarint([]byte{0x81, 0}, length << 3), 1, 2, 3)
+		_, err = Deserialize(bogus)
+		assert.NotNil(t, err)
+		_, err = NewStreamDecoder(bytes.NewReader(bogus)).Remaining()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestFrontCodedWithOptions(t *testing.T) {
+	atoms := zipkinAtoms()
+	serialized := SerializeFrontCoded(atoms)
+
+	decoded, report, err := DeserializeWithOptions(serialized, DecodeOptions{MaxAtoms: 3, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, []Atom{atoms[0], atoms[1], TrimMarker}, decoded)
+
+	// Discarded bytes are counted in the front-coded input, not in the decoded atoms
+	truncated := serialized[:len(serialized)-3]
+	decoded, report, err = DeserializeWithOptions(truncated, DecodeOptions{Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, append(append([]Atom(nil), atoms[:len(atoms)-1]...), TrimMarker), decoded)
+	assert.Equal(t, len(truncated) - (FrontCodedSize(atoms[:len(atoms)-1])), report.DiscardedBytes)
+}
+
+// Each atom extends the previous one by a byte, so the decoded size is quadratic in the input size
+func TestFrontCodedMaxBytesLimitsDecodedSize(t *testing.T) {
+	var atoms []Atom
+	for i := 1; i <= 1000; i++ { atoms = append(atoms, make(Atom, i)) }
+	serialized := SerializeFrontCoded(atoms)
+	assert.True(t, len(serialized) <= 4096)
+
+	decoded, _, err := DeserializeWithOptions(serialized, DecodeOptions{MaxBytes: 4096})
+	assert.NotNil(t, err)
+	assert.True(t, SerializedSize(decoded) <= 4096)
+
+	decoded, report, err := DeserializeWithOptions(serialized, DecodeOptions{MaxBytes: 4096, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.True(t, SerializedSize(decoded) <= 4096)
+	assert.True(t, report.DiscardedBytes > 0 && report.DiscardedBytes < len(serialized))
+}
+
+func BenchmarkSerializedSize(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	cases := []struct{
+		name  string
+		atoms []Atom
+	}{
+		{"zipkin", zipkinAtoms()},
+		{"set-100", setAtoms(r, 100)},
+		{"set-1000", setAtoms(r, 1000)},
+		{"random-100", sortAtoms(randomAtoms(r, 100))},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name + "/varint", func(b *testing.B) {
+			for i := 0; i < b.N; i++ { Serialize(c.atoms) }
+			b.ReportMetric(float64(SerializedSize(c.atoms)), "bytes")
+		})
+		b.Run(c.name + "/frontcoded", func(b *testing.B) {
+			for i := 0; i < b.N; i++ { SerializeFrontCoded(c.atoms) }
+			b.ReportMetric(float64(FrontCodedSize(c.atoms)), "bytes")
+		})
+	}
+}
+
+func BenchmarkDeserializeFrontCoded(b *testing.B) {
+	atoms := setAtoms(rand.New(rand.NewSource(0)), 1000)
+	b.Run("varint", func(b *testing.B) {
+		serialized := Serialize(atoms)
+		for i := 0; i < b.N; i++ { Deserialize(serialized) }
+	})
+	b.Run("frontcoded", func(b *testing.B) {
+		serialized := SerializeFrontCoded(atoms)
+		for i := 0; i < b.N; i++ { Deserialize(serialized) }
+	})
+}
@@ -0,0 +1,108 @@
+package atomlayer
+
+import (
+	"fmt"
+)
+
+// Limits to impose when deserializing baggage from an untrusted source.  A zero value for any limit means that limit
+// is not enforced, so the zero DecodeOptions behaves exactly like Deserialize.
+type DecodeOptions struct {
+	MaxBytes    int              // Maximum total serialized size of the baggage, both as received and once decoded
+	MaxAtoms    int              // Maximum number of atoms
+	MaxAtomSize int              // Maximum length of any single atom
+	Validate    func(Atom) error // Optional check applied to every atom, eg. baggageprotocol.MaxDepth
+	Salvage     bool             // If true, keep the valid prefix and append a TrimMarker rather than failing
+}
+
+// Describes what, if anything, was discarded by DeserializeWithOptions in salvage mode
+type DecodeReport struct {
+	Salvaged       bool  // True if some of the input was discarded and a TrimMarker was appended
+	DiscardedBytes int   // The number of serialized bytes that were discarded
+	Cause          error // The limit violation or malformed input that caused the discard
+}
+
+// Deserializes a baggage context from bytes, enforcing the provided limits.  If a limit is exceeded or the input is
+// malformed, then by default the atoms decoded so far are returned along with an error, as with Deserialize.  In
+// salvage mode, no error is returned; instead the longest valid prefix that still fits the limits once a TrimMarker is
+// appended is returned, and the report describes what was discarded.
+//
+// Front-coded input (see SerializeFrontCoded) can decode to far more bytes than it occupies, so MaxBytes is also
+// enforced on the Serialize size of the atoms as they are decoded, which stops decoding before a small input can
+// expand into a large context.
+func DeserializeWithOptions(serialized []byte, options DecodeOptions) (atoms []Atom, report DecodeReport, err error) {
+	input := serialized
+
+	// Check the overall size before doing any decoding
+	var truncated error
+	if options.MaxBytes > 0 && len(input) > options.MaxBytes {
+		truncated = exceededMaxBytes(len(input), options.MaxBytes)
+		if !options.Salvage { return nil, report, truncated }
+		input = input[:options.MaxBytes]
+	}
+
+	// Decode atoms until we run out or violate a limit.  In salvage mode, note the input offset following each atom, so
+	// that the discarded bytes can be reported
+	var cause error
+	var size int
+	offsets := []int{0}
+	d := NewDecoder(input)
+	for next := d.Next(); next != nil && cause == nil; next = d.Next() {
+		size += next.serializedSize()
+		switch {
+		case options.MaxAtomSize > 0 && len(next) > options.MaxAtomSize:	cause = exceededMaxAtomSize(len(next), options.MaxAtomSize)
+		case options.MaxAtoms > 0 && len(atoms) >= options.MaxAtoms:		cause = exceededMaxAtoms(options.MaxAtoms)
+		case options.MaxBytes > 0 && size > options.MaxBytes:				cause = exceededMaxDecodedBytes(options.MaxBytes)
+		case options.Validate != nil:										cause = options.Validate(next)
+		}
+		if cause == nil { atoms = append(atoms, next) }
+		if cause == nil && options.Salvage { offsets = append(offsets, d.Offset()) }
+	}
+
+	switch {
+	case truncated != nil:	cause = truncated	// Any decoding error is a consequence of truncating the input
+	case cause == nil:		cause = d.Err
+	}
+
+	switch {
+	case cause == nil:		return atoms, report, nil
+	case !options.Salvage:	return atoms, report, cause
+	default:				return salvage(atoms, offsets, len(serialized), options, cause)
+	}
+}
+
+// Drops atoms from the tail of the valid prefix until a TrimMarker can be appended without violating the limits.  The
+// input offset following the i'th atom is offsets[i+1].
+func salvage(atoms []Atom, offsets []int, inputSize int, options DecodeOptions, cause error) ([]Atom, DecodeReport, error) {
+	size := SerializedSize(atoms)
+	for len(atoms) > 0 && !fitsWithMarker(len(atoms), size, options) {
+		size -= atoms[len(atoms)-1].serializedSize()
+		atoms = atoms[:len(atoms)-1]
+	}
+
+	report := DecodeReport{Salvaged: true, DiscardedBytes: inputSize - offsets[len(atoms)], Cause: cause}
+	if fitsWithMarker(len(atoms), size, options) {
+		atoms = append(atoms, TrimMarker)
+	}
+	return atoms, report, nil
+}
+
+func fitsWithMarker(count, size int, options DecodeOptions) bool {
+	markerSize := TrimMarker.serializedSize()
+	return (options.MaxAtoms <= 0 || count+1 <= options.MaxAtoms) && (options.MaxBytes <= 0 || size+markerSize <= options.MaxBytes)
+}
+
+func exceededMaxBytes(size, max int) error {
+	return fmt.Errorf("Serialized baggage of %v bytes exceeds the limit of %v bytes", size, max)
+}
+
+func exceededMaxDecodedBytes(max int) error {
+	return fmt.Errorf("Decoded baggage exceeds the limit of %v bytes", max)
+}
+
+func exceededMaxAtoms(max int) error {
+	return fmt.Errorf("Baggage exceeds the limit of %v atoms", max)
+}
+
+func exceededMaxAtomSize(size, max int) error {
+	return fmt.Errorf("Encountered %v-length atom which exceeds the limit of %v bytes", size, max)
+}
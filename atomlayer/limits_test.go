@@ -0,0 +1,101 @@
+package atomlayer
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"fmt"
+)
+
+func TestDeserializeWithNoLimits(t *testing.T) {
+	atomContext := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{}, Atom{1}, Atom{1}}
+
+	atoms, report, err := DeserializeWithOptions(Serialize(atomContext), DecodeOptions{})
+	assert.Nil(t, err)
+	assert.False(t, report.Salvaged)
+	assert.Equal(t, atomContext, atoms)
+}
+
+func TestDeserializeMaxBytes(t *testing.T) {
+	atomContext := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{1}}
+	bytes := Serialize(atomContext)
+	assert.Equal(t, 12, len(bytes))
+
+	atoms, _, err := DeserializeWithOptions(bytes, DecodeOptions{MaxBytes: 12})
+	assert.Nil(t, err)
+	assert.Equal(t, atomContext, atoms)
+
+	atoms, _, err = DeserializeWithOptions(bytes, DecodeOptions{MaxBytes: 11})
+	assert.NotNil(t, err)
+	assert.Empty(t, atoms)
+
+	atoms, report, err := DeserializeWithOptions(bytes, DecodeOptions{MaxBytes: 11, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.NotNil(t, report.Cause)
+	assert.Equal(t, 2, report.DiscardedBytes)
+	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, TrimMarker}, atoms)
+	assert.True(t, SerializedSize(atoms) <= 11)
+
+	atoms, report, err = DeserializeWithOptions(bytes, DecodeOptions{MaxBytes: 10, Salvage: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, report.DiscardedBytes)
+	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, TrimMarker}, atoms)
+}
+
+func TestDeserializeMaxAtoms(t *testing.T) {
+	atomContext := []Atom{Atom{1}, Atom{2}, Atom{3}, Atom{4}}
+	bytes := Serialize(atomContext)
+
+	atoms, _, err := DeserializeWithOptions(bytes, DecodeOptions{MaxAtoms: 3})
+	assert.NotNil(t, err)
+	assert.Equal(t, atomContext[:3], atoms)
+
+	atoms, report, err := DeserializeWithOptions(bytes, DecodeOptions{MaxAtoms: 3, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, 4, report.DiscardedBytes)
+	assert.Equal(t, []Atom{Atom{1}, Atom{2}, TrimMarker}, atoms)
+}
+
+func TestDeserializeMaxAtomSize(t *testing.T) {
+	atomContext := []Atom{Atom{1}, Atom{2,2,2,2}, Atom{3}}
+	bytes := Serialize(atomContext)
+
+	atoms, _, err := DeserializeWithOptions(bytes, DecodeOptions{MaxAtomSize: 3})
+	assert.NotNil(t, err)
+	assert.Equal(t, atomContext[:1], atoms)
+
+	atoms, report, err := DeserializeWithOptions(bytes, DecodeOptions{MaxAtomSize: 3, Salvage: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 7, report.DiscardedBytes)
+	assert.Equal(t, []Atom{Atom{1}, TrimMarker}, atoms)
+}
+
+func TestDeserializeValidate(t *testing.T) {
+	atomContext := []Atom{Atom{1}, Atom{2}, Atom{3}}
+	bytes := Serialize(atomContext)
+	reject2 := func(atom Atom) error {
+		if len(atom) > 0 && atom[0] == 2 { return fmt.Errorf("rejected") }
+		return nil
+	}
+
+	atoms, _, err := DeserializeWithOptions(bytes, DecodeOptions{Validate: reject2})
+	assert.NotNil(t, err)
+	assert.Equal(t, atomContext[:1], atoms)
+
+	atoms, report, err := DeserializeWithOptions(bytes, DecodeOptions{Validate: reject2, Salvage: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "rejected", report.Cause.Error())
+	assert.Equal(t, []Atom{Atom{1}, TrimMarker}, atoms)
+}
+
+func TestDeserializeSalvageMalformed(t *testing.T) {
+	atomContext := []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, Atom{1}}
+	bytes := Serialize(atomContext)
+
+	atoms, report, err := DeserializeWithOptions(bytes[:11], DecodeOptions{Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, 1, report.DiscardedBytes)
+	assert.Equal(t, []Atom{Atom{1,2,3,4,5}, Atom{7,3,7}, TrimMarker}, atoms)
+}
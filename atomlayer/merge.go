@@ -0,0 +1,76 @@
+package atomlayer
+
+// Merges any number of BaggageContexts by lexicographically comparing their atoms.  The result is the same as merging
+// the inputs pairwise with Merge, but the merged atoms are allocated only once and each atom is compared O(log k)
+// times, so merging a fan-in of k contexts is not quadratic in k.
+func MergeAll(inputs ...[]Atom) []Atom {
+	switch len(inputs) {
+	case 0: return nil
+	case 1: return Merge(inputs[0], nil)
+	case 2: return Merge(inputs[0], inputs[1])
+	}
+
+	size, allNil := 0, true
+	h := make(mergeHeap, 0, len(inputs))
+	for _, input := range inputs {
+		size += len(input)
+		allNil = allNil && input == nil
+		if len(input) > 0 { h = append(h, input) }
+	}
+	if allNil { return nil }
+
+	merged := make([]Atom, 0, size)
+	n := len(h)
+	for i := n/2 - 1; i >= 0; i-- { h.down(i, n) }
+
+	for n > 0 {
+		min := h[0][0]
+		merged = append(merged, min)
+
+		// Pop every input whose next atom equals min, setting it aside past the end of the heap.  Inputs are only
+		// advanced once they've all been popped, so duplicates within a single input are retained, as with Merge
+		end := n
+		for n > 0 && equal(h[0][0], min) {
+			n--
+			h[0], h[n] = h[n], h[0]
+			h.down(0, n)
+		}
+
+		// Advance the set-aside inputs and push any that aren't exhausted back onto the heap
+		for i := n; i < end; i++ {
+			if remaining := h[i][1:]; len(remaining) > 0 {
+				h[n] = remaining
+				h.up(n)
+				n++
+			}
+		}
+	}
+	return merged
+}
+
+// A min-heap of partially merged inputs, ordered by each input's next atom
+type mergeHeap [][]Atom
+
+func (h mergeHeap) less(i, j int) bool {
+	return compare(h[i][0], h[j][0]) < 0
+}
+
+func (h mergeHeap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) { return }
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+func (h mergeHeap) down(i, n int) {
+	for {
+		smallest, left, right := i, 2*i + 1, 2*i + 2
+		if left < n && h.less(left, smallest) { smallest = left }
+		if right < n && h.less(right, smallest) { smallest = right }
+		if smallest == i { return }
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+}
@@ -0,0 +1,94 @@
+package atomlayer
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"bytes"
+	"fmt"
+)
+
+func TestMergeAllNils(t *testing.T) {
+	assert.Equal(t, []Atom(nil), MergeAll())
+	assert.Equal(t, []Atom(nil), MergeAll(nil))
+	assert.Equal(t, []Atom(nil), MergeAll(nil, nil, nil))
+	assert.Equal(t, []Atom{}, MergeAll(nil, []Atom{}, nil))
+	assert.Equal(t, []Atom{Atom{1}}, MergeAll(nil, []Atom{Atom{1}}, nil))
+}
+
+func TestMergeAllSimple(t *testing.T) {
+	a := []Atom{Atom{0,1,1,1}, Atom{1}}
+	b := []Atom{Atom{0,1,1,1}, Atom{2}}
+	c := []Atom{Atom{1}, Atom{0,1,1,1}}
+	assert.Equal(t, []Atom{Atom{0,1,1,1}, Atom{1}, Atom{0,1,1,1}, Atom{2}}, MergeAll(a, b, c))
+	assert.Equal(t, Merge(Merge(a, b), c), MergeAll(a, b, c))
+}
+
+func TestMergeAllRetainsDuplicatesWithinAnInput(t *testing.T) {
+	a := []Atom{Atom{1}, Atom{1}, Atom{2}}
+	b := []Atom{Atom{1}}
+	c := []Atom{Atom{2}}
+	assert.Equal(t, []Atom{Atom{1}, Atom{1}, Atom{2}}, MergeAll(a, b, c))
+	assert.Equal(t, Merge(Merge(a, b), c), MergeAll(a, b, c))
+}
+
+func TestMergeAllMatchesPairwiseMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for trial := 0; trial < 200; trial++ {
+		inputs := randomBranches(r, 1 + r.Intn(10), r.Intn(20), r.Intn(5))
+
+		var expected []Atom
+		for _, input := range inputs {
+			expected = Merge(expected, input)
+		}
+		assert.Equal(t, expected, MergeAll(inputs...))
+	}
+}
+
+// Generates k sorted inputs that share some common atoms, as though they had branched from a common ancestor
+func randomBranches(r *rand.Rand, k, shared, unique int) [][]Atom {
+	common := randomAtoms(r, shared)
+	inputs := make([][]Atom, k)
+	for i := range inputs {
+		inputs[i] = sortAtoms(append(append([]Atom(nil), common...), randomAtoms(r, unique)...))
+	}
+	return inputs
+}
+
+func randomAtoms(r *rand.Rand, n int) []Atom {
+	atoms := make([]Atom, n)
+	for i := range atoms {
+		atoms[i] = make(Atom, 1 + r.Intn(8))
+		r.Read(atoms[i])
+	}
+	return atoms
+}
+
+func sortAtoms(atoms []Atom) []Atom {
+	sort.Slice(atoms, func(i, j int) bool { return bytes.Compare(atoms[i], atoms[j]) < 0 })
+	return atoms
+}
+
+func BenchmarkMerge(b *testing.B) {
+	for _, k := range []int{2, 16, 1000} {
+		inputs := randomBranches(rand.New(rand.NewSource(0)), k, 50, 2)
+
+		b.Run(fmt.Sprintf("MergeAll/%v", k), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				MergeAll(inputs...)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Pairwise/%v", k), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var merged []Atom
+				for _, input := range inputs {
+					merged = Merge(merged, input)
+				}
+			}
+		})
+	}
+}
@@ -49,6 +49,9 @@ func EncodeUnsignedLexVarint(value uint64) []byte {
 	return bytes
 }
 
+// The first bit is the sign, set for non-negative values, followed by one set bit for each byte after the first.  For 8
+// and 9 byte values, the first bit of the second byte distinguishes the two.  Negative values are the complement of
+// the encoding of -(value + 1)
 func EncodeSignedLexVarint(value int64) []byte {
 	// Negative values just invert the bytes
 	if value < 0 {
@@ -65,9 +68,9 @@ func EncodeSignedLexVarint(value int64) []byte {
 			value >>= 8
 		}
 
-		// Encode the size in the first and possibly second byte
+		// Encode the sign and size in the first and possibly second byte
 		if size == 9 { bytes[1] |= 0x80 }
-		bytes[0] |= 0x7F & (0xFF << (9 - uint(size)))
+		bytes[0] |= byte(0xFF << (8 - min(size, 8)))
 		return bytes
 	}
 }
@@ -172,7 +175,7 @@ func DecodeSignedLexVarint(bytes []byte) (int64, int) {
 
 	// Second byte
 	switch size {
-	case 8: case 9: result = (result << 7) | uint64(b(1) & 0x7F)
+	case 8, 9: result = (result << 7) | uint64(b(1) & 0x7F)
 	default: result = (result << 8) | uint64(b(1))
 	}
 
@@ -3,6 +3,7 @@ package baggageprotocol
 import (
   "testing"
   "github.com/stretchr/testify/assert"
+  "math"
   "math/rand"
 	"bytes"
 )
@@ -278,3 +279,52 @@ func TestLexVarInt64Simple(t *testing.T) {
 	assert.Equal(t, 9, length)
 	assert.Equal(t, int64(9223372036854775807), decoded)
 }
+
+func TestWriteReadLexVarInt64(t *testing.T) {
+	values := []int64{math.MinInt64, -1<<55 - 1, -1<<55, -65, -64, -1, 0, 1, 63, 64, 1<<13, 1<<20, 1<<27, 1<<34, 1<<41, 1<<48, 1<<55 - 1, 1<<55, math.MaxInt64}
+	for i, value := range values {
+		encoded := EncodeSignedLexVarint(value)
+		assert.Equal(t, SizeSignedLexVarint(value), len(encoded))
+
+		decoded, length := DecodeSignedLexVarint(encoded)
+		assert.Equal(t, len(encoded), length)
+		assert.Equal(t, value, decoded)
+
+		if i > 0 { assert.Equal(t, -1, bytes.Compare(EncodeSignedLexVarint(values[i-1]), encoded)) }
+	}
+}
+
+// The bytes written for signed values are part of the wire format, so must not change.  The table includes every
+// value that TestLexVarInt64Simple decodes, so the encoder writes exactly the bytes the decoder has always expected
+func TestEncodeSignedLexVarintGolden(t *testing.T) {
+	golden := []struct {
+		value   int64
+		encoded []byte
+	}{
+		{math.MinInt64, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{-1<<55 - 1, []byte{0x00, 0x7f, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{-1<<55, []byte{0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{-8193, []byte{0x1f, 0xdf, 0xff}},
+		{-65, []byte{0x3f, 0xbf}},
+		{-64, []byte{0x40}},
+		{-19, []byte{0x6d}},
+		{-4, []byte{0x7c}},
+		{-1, []byte{0x7f}},
+		{0, []byte{0x80}},
+		{1, []byte{0x81}},
+		{19, []byte{0x93}},
+		{63, []byte{0xbf}},
+		{64, []byte{0xc0, 0x40}},
+		{8191, []byte{0xdf, 0xff}},
+		{8192, []byte{0xe0, 0x20, 0x00}},
+		{1<<55 - 1, []byte{0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{1<<55, []byte{0xff, 0x80, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, g := range golden {
+		assert.Equal(t, g.encoded, EncodeSignedLexVarint(g.value), "%v", g.value)
+		decoded, length := DecodeSignedLexVarint(g.encoded)
+		assert.Equal(t, len(g.encoded), length, "%v", g.value)
+		assert.Equal(t, g.value, decoded)
+	}
+}
@@ -16,12 +16,13 @@ func IsData(atom atomlayer.Atom) bool {
 	return len(atom) != 0 && (atom[0] & 0x80) == 0x00
 }
 
+// Header atoms have the prefix byte 1LLLLK00, where LLLL is 15 minus the level and K is set for keyed headers
 func IsIndexedHeader(atom atomlayer.Atom) bool {
-	return len(atom) != 0 && (atom[0] & 0x03) == 0x00
+	return IsHeader(atom) && (atom[0] & 0x07) == 0x00
 }
 
 func IsKeyedHeader(atom atomlayer.Atom) bool {
-	return len(atom) != 0 && (atom[0] & 0x03) == 0x02
+	return IsHeader(atom) && (atom[0] & 0x07) == 0x04
 }
 
 func HeaderLevel(atom atomlayer.Atom) (int, error) {
@@ -59,4 +60,18 @@ func MakeKeyedHeader(level int, key []byte) []byte {
 
 func MakeDataAtom(payload []byte) []byte {
 	return append(append(make([]byte, 0, len(payload)+1), 0x00), payload...)
-}
\ No newline at end of file
+}
+
+// Returns a validation function for atomlayer.DecodeOptions that rejects header atoms more than maxDepth levels deep.
+// A maxDepth of 1 only permits root bags; a maxDepth of 2 permits root bags and their children; and so on.  Like the
+// other limits in DecodeOptions, a maxDepth of 0 or less is not enforced.
+func MaxDepth(maxDepth int) func(atomlayer.Atom) error {
+	return func(atom atomlayer.Atom) error {
+		if maxDepth <= 0 || !IsHeader(atom) { return nil }
+		switch level, err := HeaderLevel(atom); {
+		case err != nil: return err
+		case level >= maxDepth: return fmt.Errorf("Header atom %v at level %v exceeds the maximum depth of %v", atom, level, maxDepth)
+		default: return nil
+		}
+	}
+}
@@ -71,4 +71,45 @@ func TestMakeHeaderAtom(t *testing.T) {
 	assert.Equal(t, []byte{252, 104, 105}, MakeKeyedHeader(0, []byte("hi")))
 	assert.Equal(t, []byte{244, 111, 107}, MakeKeyedHeader(1, []byte("ok")))
 
-}
\ No newline at end of file
+}
+
+func TestMaxDepth(t *testing.T) {
+	validate := MaxDepth(2)
+	assert.Nil(t, validate(MakeIndexedHeader(0, 5)))
+	assert.Nil(t, validate(MakeIndexedHeader(1, 5)))
+	assert.NotNil(t, validate(MakeIndexedHeader(2, 5)))
+	assert.NotNil(t, validate(MakeKeyedHeader(3, []byte("hi"))))
+	assert.Nil(t, validate(MakeDataAtom([]byte{1, 2, 3})))
+	assert.Nil(t, validate(atomlayer.TrimMarker))
+
+	baggage := atomlayer.Serialize(atoms(header(0, 1), header(1, 2), header(2, 3), data(5)))
+	decoded, report, err := atomlayer.DeserializeWithOptions(baggage, atomlayer.DecodeOptions{Validate: validate, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, atoms(header(0, 1), header(1, 2), atomlayer.TrimMarker), decoded)
+
+	// Zero means no limit
+	assert.Nil(t, MaxDepth(0)(MakeIndexedHeader(0, 5)))
+	assert.Nil(t, MaxDepth(-1)(MakeIndexedHeader(15, 5)))
+}
+
+// The kind of header is bit 0x04 of the prefix byte.  Checking the low two bits instead, as IsIndexedHeader and
+// IsKeyedHeader once did, classifies keyed headers and data atoms as indexed headers, and nothing as a keyed header.
+func TestHeaderKinds(t *testing.T) {
+	assert.True(t, IsIndexedHeader(MakeIndexedHeader(3, 5)))
+	assert.False(t, IsKeyedHeader(MakeIndexedHeader(3, 5)))
+	assert.True(t, IsKeyedHeader(MakeKeyedHeader(3, []byte("key"))))
+	assert.False(t, IsIndexedHeader(MakeKeyedHeader(3, []byte("key"))))
+	assert.False(t, IsIndexedHeader(MakeDataAtom(nil)))
+	assert.False(t, IsKeyedHeader(atomlayer.TrimMarker))
+
+	for level := 0; level < 16; level++ {
+		indexed, keyed := MakeIndexedHeader(level, 1), MakeKeyedHeader(level, []byte("k"))
+		assert.True(t, IsIndexedHeader(indexed) && !IsKeyedHeader(indexed), "level %v", level)
+		assert.True(t, IsKeyedHeader(keyed) && !IsIndexedHeader(keyed), "level %v", level)
+	}
+	for _, prefix := range []byte{0x00, 0x02, 0x04, 0x7f} {
+		assert.False(t, IsIndexedHeader(atomlayer.Atom{prefix, 1}))
+		assert.False(t, IsKeyedHeader(atomlayer.Atom{prefix, 1}))
+	}
+}
@@ -10,9 +10,14 @@ type Reader struct {
 	next       atomlayer.Atom
 	currentPath []atomlayer.Atom
 	remaining  []atomlayer.Atom
+	source     *atomlayer.Decoder		// If non-nil, atoms are pulled lazily from source instead of remaining
+	bound      atomlayer.Atom			// When reading from source, stop at the first atom >= bound
 	Skipped    []atomlayer.Atom
 	level      int
-	Overflowed bool
+	Overflowed bool						// True if any of the bags being read lost data due to trimming
+	OverflowPaths [][]atomlayer.Atom	// The fully qualified paths of the bags that lost data.  An empty path
+										// indicates that the bag being read may have been dropped entirely
+	basePath   []atomlayer.Atom			// Header of the bag opened with Open, which precedes currentPath
 	Err        error
 }
 
@@ -28,20 +33,70 @@ func Read(baggage []atomlayer.Atom) *Reader {
 func Open(baggage []atomlayer.Atom, bagIndex uint64) *Reader {
 	var r Reader
 	target := MakeIndexedHeader(0, bagIndex)
-	exists, overflowed, i := find(baggage, 0, target)
+	exists, _, i := find(baggage, 0, target)
 
-	r.Overflowed = overflowed
 	r.level = 0
+	r.basePath = []atomlayer.Atom{target}
 
+	// Only trim markers within the bag itself indicate that it lost data.  If the bag doesn't exist, a trim marker
+	// preceding all bags, or ending the baggage if no bags follow, indicates it may have been dropped entirely
 	if exists {
 		_,_,j := find(baggage, i+1, target)
 		r.remaining = baggage[i+1:j]
+		r.recordOverflows(OverflowPaths(baggage[i:j]))
+	} else {
+		var preceding overflowTracker
+		for _, atom := range baggage[:i] { preceding.visit(atom) }
+		if preceding.mayHaveDroppedBags(i == len(baggage)) { r.recordOverflow(nil) }
 	}
 
 	r.advance()
 	return &r
 }
 
+// Like Open, but reads the bag directly from serialized bytes.  Atoms are decoded lazily as the Reader advances, and
+// atoms following the bag are never decoded.
+func OpenSerialized(serialized []byte, bagIndex uint64) *Reader {
+	return OpenDecoder(atomlayer.NewDecoder(serialized), bagIndex)
+}
+
+// Like Open, but pulls atoms lazily from the provided Decoder.  The Decoder is advanced past any preceding bags; once
+// the bag has been read, the Decoder is positioned at the first atom following the bag.
+func OpenDecoder(d *atomlayer.Decoder, bagIndex uint64) *Reader {
+	var r Reader
+	target := MakeIndexedHeader(0, bagIndex)
+	r.level = 0
+	r.basePath = []atomlayer.Atom{target}
+
+	// Seek to the bag, noting any trim markers that indicate it may have been dropped
+	var preceding overflowTracker
+	for next := d.Peek(); next != nil && bytes.Compare(next, target) < 0; next = d.Peek() {
+		preceding.visit(d.Next())
+	}
+
+	// If possible, look ahead for trim markers within the bag, so that overflow is known before reading.  Otherwise,
+	// trim markers are only discovered as the bag is read
+	switch exists := bytes.Equal(d.Peek(), target) && d.Peek() != nil; {
+	case exists:
+		d.Next()
+		r.source = d
+		r.bound = target
+		if ahead := d.Fork(); ahead != nil {
+			within := overflowTracker{path: []atomlayer.Atom{target}}
+			for next := ahead.Next(); next != nil && bytes.Compare(next, target) < 0; next = ahead.Next() {
+				within.visit(next)
+			}
+			r.recordOverflows(within.paths)
+		}
+	case preceding.mayHaveDroppedBags(d.Peek() == nil):
+		r.recordOverflow(nil)
+	}
+
+	r.seterror(d.Err)
+	r.advance()
+	return &r
+}
+
 // Closes the Reader, treating all remaining atoms as skipped
 func (r *Reader) Close() {
 	// Exit any current bags
@@ -51,14 +106,12 @@ func (r *Reader) Close() {
 	r.advanceToNextHeader()
 
 	// Remaining are skipped
-	if r.next != nil {
+	remaining := overflowTracker{path: r.path()}
+	for ; r.next != nil; r.advance() {
 		r.Skipped = append(r.Skipped, r.next)
-		if len(r.remaining) > 0 {
-			r.Skipped = append(r.Skipped, r.remaining...)
-			r.remaining = nil
-		}
-		r.next = nil
+		remaining.visit(r.next)
 	}
+	r.recordOverflows(remaining.paths)
 }
 
 // Advances r.next zero or more atoms, until it's a header atom.  If it's already a header atom, does nothing.
@@ -67,7 +120,7 @@ func (r *Reader) advanceToNextHeader() (atomlayer.Atom, int) {
 	for {
 		switch {
 		case r.next == nil: 					goto noheader							// End of baggage or error
-		case atomlayer.IsTrimMarker(r.next): 	r.Overflowed = true; goto nextatom		// Handle overflow marker
+		case atomlayer.IsTrimMarker(r.next): 	r.recordOverflow(r.path()); goto nextatom	// Handle overflow marker
 		case IsHeader(r.next): 					goto foundheader 						// Found the next header atom
 		case IsData(r.next): 					goto nextatom							// Skip any data atoms
 		}
@@ -154,6 +207,9 @@ func (r *Reader) enter(target []byte) bool {
 // Skips bags, treating them as unprocessed, until we reach a bag at or below the specified level
 func (r *Reader) skipuntil(stopAtLevel int) {
 	skippedAtoms := append(append([]atomlayer.Atom(nil), r.currentPath...), r.next)
+	skipped := overflowTracker{path: r.path()}
+	skipped.visit(r.next)
+	markerSkipped := false
 	r.advance()
 	for {
 		// Non-header atoms
@@ -167,13 +223,14 @@ func (r *Reader) skipuntil(stopAtLevel int) {
 		switch level, err := HeaderLevel(r.next); {
 		case err != nil: 						r.seterror(err); goto finish		// Invalid header, abort
 		case level <= stopAtLevel:				goto finish							// End of the bag being skipped
-		default:								goto skipatom						// A descendent bag; keep skipping
+		default:								skipped.visit(r.next); goto skipatom	// A descendent bag; keep skipping
 		}
 
 		trimmarker:
-		switch r.Overflowed {
+		skipped.visit(r.next)
+		switch markerSkipped {
 		case true: goto nextatom											// Ignore redundant trim marker
-		case false: r.Overflowed = true; goto skipatom								// First trim marker seen
+		case false: markerSkipped = true; goto skipatom						// First trim marker seen
 		}
 
 		skipatom:
@@ -184,6 +241,7 @@ func (r *Reader) skipuntil(stopAtLevel int) {
 	}
 
 	finish:
+	r.recordOverflows(skipped.paths)
 	r.Skipped = atomlayer.Merge(r.Skipped, skippedAtoms);
 }
 
@@ -213,7 +271,7 @@ func (r *Reader) Next() []byte {
 		// Non-data atoms
 		switch {
 		case r.next == nil:						goto nodata								// End of baggage or an error
-		case atomlayer.IsTrimMarker(r.next): 	r.Overflowed = true; goto nextatom		// Trim marker, continue
+		case atomlayer.IsTrimMarker(r.next): 	r.recordOverflow(r.path()); goto nextatom	// Trim marker, continue
 		case !IsData(r.next): 					goto nodata								// Not a data atom
 		}
 
@@ -244,9 +302,26 @@ func (r *Reader) seterror(err error) error {
 	return r.Err
 }
 
+// Returns the fully qualified path of the current bag
+func (r *Reader) path() []atomlayer.Atom {
+	return append(append([]atomlayer.Atom(nil), r.basePath...), r.currentPath...)
+}
+
+func (r *Reader) recordOverflow(path []atomlayer.Atom) {
+	r.Overflowed = true
+	r.OverflowPaths = addPath(r.OverflowPaths, path)
+}
+
+func (r *Reader) recordOverflows(paths [][]atomlayer.Atom) {
+	for _, path := range paths {
+		r.recordOverflow(path)
+	}
+}
+
 func (r *Reader) advance() {
 	switch {
 	case r.Err != nil: 							goto exhausted 							// Error occurred - stop
+	case r.source != nil:						goto decode								// Reading lazily
 	case len(r.remaining) == 0: 				goto exhausted							// No atoms remaining
 	default: 									goto advance							// Advance to next atom
 	}
@@ -256,6 +331,14 @@ func (r *Reader) advance() {
 	r.remaining = r.remaining[1:]
 	return
 
+	decode:
+	switch next := r.source.Peek(); {
+	case r.source.Err != nil:					r.seterror(r.source.Err); return		// Malformed serialized bytes
+	case next == nil:							goto exhausted							// No atoms remaining
+	case bytes.Compare(next, r.bound) >= 0:		goto exhausted							// Reached end of the bag
+	default:									r.next = r.source.Next(); return		// Advance to next atom
+	}
+
 	exhausted:
 	r.next = nil
 	return
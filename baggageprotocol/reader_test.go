@@ -736,10 +736,12 @@ func TestOpenBagOverflow(t *testing.T) {
 	)
 
 	r := Open(baggage, 3)
-	assert.True(t, r.Overflowed)
+	assert.False(t, r.Overflowed)
+	assert.Empty(t, r.OverflowPaths)
 
 	r = Open(baggage, 0)
-	assert.False(t, r.Overflowed)
+	assert.True(t, r.Overflowed)
+	assert.Equal(t, [][]atomlayer.Atom{atoms(header(0, 0))}, r.OverflowPaths)
 
 	baggage = atoms(
 		header(0, 0),
@@ -751,8 +753,162 @@ func TestOpenBagOverflow(t *testing.T) {
 	)
 
 	r = Open(baggage, 3)
-	assert.False(t, r.Overflowed)
+	assert.True(t, r.Overflowed)
 
 	r = Open(baggage, 0)
 	assert.False(t, r.Overflowed)
-}
\ No newline at end of file
+}
+
+func TestOpenBagOverflowPaths(t *testing.T) {
+	baggage := atoms(
+		header(0, 3),
+			data(6),
+			header(1, 0),
+				data(1),
+				[]byte{},
+			header(1, 2),
+				header(2, 5),
+					[]byte{},
+				data(2),
+		header(0, 4),
+			data(1),
+	)
+
+	// Overflow is known before reading, and attributed to the child bags that lost data
+	expected := [][]atomlayer.Atom{atoms(header(0, 3), header(1, 0)), atoms(header(0, 3), header(1, 2), header(2, 5))}
+	r := Open(baggage, 3)
+	assert.True(t, r.Overflowed)
+	assert.Equal(t, expected, r.OverflowPaths)
+
+	r = OpenSerialized(atomlayer.Serialize(baggage), 3)
+	assert.True(t, r.Overflowed)
+	assert.Equal(t, expected, r.OverflowPaths)
+
+	r = Open(baggage, 4)
+	assert.False(t, r.Overflowed)
+
+	// Reading the entire baggage discovers the same paths
+	r = Read(baggage)
+	r.Close()
+	assert.Equal(t, expected, r.OverflowPaths)
+
+	// A marker preceding all bags means a missing bag may have been dropped, but existing bags were not
+	baggage = atoms([]byte{}, header(0, 3), data(6))
+	r = Open(baggage, 3)
+	assert.False(t, r.Overflowed)
+
+	r = Open(baggage, 2)
+	assert.True(t, r.Overflowed)
+	assert.Equal(t, [][]atomlayer.Atom{atoms()}, r.OverflowPaths)
+
+	r = OpenSerialized(atomlayer.Serialize(baggage), 2)
+	assert.True(t, r.Overflowed)
+}
+
+func TestOpenTrimmedBaggage(t *testing.T) {
+	baggage := atoms(
+		header(0, 1),
+			data(1),
+		header(0, 2),
+			data(1), data(2), data(3), data(4), data(5), data(6), data(7), data(8),
+	)
+
+	// Trim drops bag 2 entirely, and places the trim marker at the end of bag 1
+	trimmed := atomlayer.Trim(baggage, 7)
+	assert.Equal(t, atoms(header(0, 1), data(1), []byte{}), trimmed)
+	assert.True(t, MayHaveDroppedBags(trimmed))
+
+	for _, index := range []uint64{2, 3} {
+		r := Open(trimmed, index)
+		assert.True(t, r.Overflowed)
+		assert.Equal(t, [][]atomlayer.Atom{atoms()}, r.OverflowPaths)
+
+		r = OpenSerialized(atomlayer.Serialize(trimmed), index)
+		assert.True(t, r.Overflowed)
+		assert.Equal(t, [][]atomlayer.Atom{atoms()}, r.OverflowPaths)
+	}
+
+	// Bags preceding the marker were kept, and can't have been dropped
+	assert.False(t, Open(trimmed, 0).Overflowed)
+	assert.False(t, OpenSerialized(atomlayer.Serialize(trimmed), 0).Overflowed)
+
+	// A marker within a bag, rather than at the end of the baggage, only means that bag lost data
+	for _, baggage := range [][]atomlayer.Atom{
+		atoms(header(0, 1), []byte{}, data(1), header(0, 3), data(1)),
+		atoms(header(0, 1), data(1), []byte{}, header(0, 3), data(1)),
+	} {
+		assert.False(t, MayHaveDroppedBags(baggage))
+		for _, index := range []uint64{0, 2, 4} {
+			assert.False(t, Open(baggage, index).Overflowed)
+			assert.False(t, OpenSerialized(atomlayer.Serialize(baggage), index).Overflowed)
+		}
+	}
+}
+
+func TestOpenSerialized(t *testing.T) {
+	baggage := atoms(
+		header(0, 3),
+			data(7),
+			[]byte{},
+		header(0, 4),
+			data(2),
+			header(1, 0),
+				data(20),
+			header(1, 1),
+				data(21),
+		header(0, 5),
+			data(11),
+	)
+	serialized := atomlayer.Serialize(baggage)
+
+	r := OpenSerialized(serialized, 4)
+	assert.False(t, r.Overflowed)
+	assert.Equal(t, []byte{2}, r.Next())
+	assert.True(t, r.EnterIndexed(0))
+	assert.Equal(t, []byte{20}, r.Next())
+	r.Exit()
+	r.Close()
+	assert.Nil(t, r.Error())
+	assert.Equal(t, atoms(header(1, 1), data(21)), r.Skipped)
+
+	expected := Open(baggage, 4)
+	expected.Next()
+	expected.EnterIndexed(0)
+	expected.Next()
+	expected.Exit()
+	expected.Close()
+	assert.Equal(t, expected.Skipped, r.Skipped)
+
+	r = OpenSerialized(serialized, 5)
+	assert.Equal(t, []byte{11}, r.Next())
+	assert.Nil(t, r.Next())
+
+	r = OpenSerialized(serialized, 6)
+	assert.Nil(t, r.Next())
+	assert.Nil(t, r.Enter())
+	assert.Nil(t, r.Error())
+}
+
+func TestOpenSerializedLeavesFollowingAtoms(t *testing.T) {
+	baggage := atoms(
+		header(0, 3),
+			data(7),
+		header(0, 4),
+			data(2),
+	)
+
+	d := atomlayer.NewDecoder(atomlayer.Serialize(baggage))
+	r := OpenDecoder(d, 3)
+	assert.Equal(t, []byte{7}, r.Next())
+	assert.Nil(t, r.Next())
+	r.Close()
+	assert.Equal(t, atomlayer.Atom(header(0, 4)), d.Peek())
+}
+
+func TestOpenSerializedMalformed(t *testing.T) {
+	serialized := atomlayer.Serialize(atoms(header(0, 3), data(7)))
+
+	r := OpenSerialized(serialized[:len(serialized)-1], 3)
+	assert.Nil(t, r.Next())
+	assert.NotNil(t, r.Error())
+}
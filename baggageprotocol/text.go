@@ -0,0 +1,163 @@
+package baggageprotocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+// This file contains a human-readable text representation of atoms, for debugging and for writing test fixtures.  Each
+// atom is written on its own line, indented according to the bag that contains it:
+//
+//   bag[2]                 an indexed root bag, with index 2; a keyed root bag would be bag"key"
+//     bag[2].[0]           an indexed child bag; header lines always show the fully qualified path
+//       0x0000000037       a data atom whose payload is written in hex
+//     bag[2]."key"         a keyed child bag
+//       "hello"            a data atom whose payload is printable text
+//       <trim>             a trim marker
+//   raw 0xf80102           an atom that is not a well-formed header or data atom
+//
+// Indentation is ignored when parsing, as are blank lines and lines starting with #.
+
+// Formats atoms as text.  Every atom is represented exactly, so ParseText(FormatText(atoms)) returns the same atoms.
+func FormatText(atoms []atomlayer.Atom) string {
+	var text strings.Builder
+	var path []atomlayer.Atom
+	for _, atom := range atoms {
+		switch level, err := HeaderLevel(atom); {
+		case atomlayer.IsTrimMarker(atom):	writeTextLine(&text, len(path), "<trim>")
+		case IsData(atom) && atom[0] == data_prefix_byte:	writeTextLine(&text, len(path), formatPayload(atom[1:]))
+		case IsHeader(atom) && err == nil && level <= len(path) && formatSegment(atom) != "":
+			path = append(path[:level], atom)
+			writeTextLine(&text, level, FormatPath(path))
+		default:							writeTextLine(&text, len(path), "raw 0x" + hex.EncodeToString(atom))
+		}
+	}
+	return text.String()
+}
+
+// Parses text written by FormatText, or by hand in the same format, back into atoms.
+func ParseText(text string) ([]atomlayer.Atom, error) {
+	var atoms []atomlayer.Atom
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		var atom atomlayer.Atom
+		var err error
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):	continue
+		case line == "<trim>":								atom = atomlayer.TrimMarker
+		case strings.HasPrefix(line, "bag"):				atom, err = parseHeader(line)
+		case strings.HasPrefix(line, "raw "):				atom, err = parseHex(strings.TrimSpace(line[4:]))
+		case strings.HasPrefix(line, "\""):					atom, err = parseQuoted(line)
+		default:											atom, err = parseHex(line); atom = MakeDataAtom(atom)
+		}
+		if err != nil { return atoms, invalidTextLine(i+1, line, err) }
+		atoms = append(atoms, atom)
+	}
+	return atoms, nil
+}
+
+func writeTextLine(text *strings.Builder, depth int, line string) {
+	text.WriteString(strings.Repeat("  ", depth))
+	text.WriteString(line)
+	text.WriteByte('\n')
+}
+
+// Formats the fully qualified path of a bag, as a sequence of header atoms, eg. bag[2].[0].  Headers that can't be
+// formatted are written in hex, eg. bag[2].<0xf1ff>, which ParseText does not accept.
+func FormatPath(path []atomlayer.Atom) string {
+	segments := make([]string, len(path))
+	for i, header := range path {
+		if segments[i] = formatSegment(header); segments[i] == "" { segments[i] = "<0x" + hex.EncodeToString(header) + ">" }
+	}
+	return "bag" + strings.Join(segments, ".")
+}
+
+// Formats a single header as [index] or "key".  Returns the empty string if the header can't be reproduced exactly
+// from its text representation.
+func formatSegment(header atomlayer.Atom) string {
+	level, _ := HeaderLevel(header)
+	switch {
+	case IsIndexedHeader(header):
+		if index, err := HeaderIndex(header); err == nil && bytes.Equal(header, MakeIndexedHeader(level, index)) {
+			return "[" + strconv.FormatUint(index, 10) + "]"
+		}
+	case IsKeyedHeader(header):
+		return strconv.Quote(string(header[1:]))
+	}
+	return ""
+}
+
+// Formats a data payload as quoted text if it's printable, or hex otherwise
+func formatPayload(payload []byte) string {
+	if isPrintable(payload) { return strconv.Quote(string(payload)) }
+	return "0x" + hex.EncodeToString(payload)
+}
+
+func isPrintable(payload []byte) bool {
+	if len(payload) == 0 || !utf8.Valid(payload) { return false }
+	for _, r := range string(payload) {
+		if !unicode.IsPrint(r) { return false }
+	}
+	return true
+}
+
+// Parses a fully qualified path as written by FormatPath, eg. bag[2].[0], returning its header atoms
+func ParsePath(text string) ([]atomlayer.Atom, error) {
+	if !strings.HasPrefix(text, "bag") { return nil, fmt.Errorf("Expected path beginning with bag") }
+	path, headers := text[3:], []atomlayer.Atom(nil)
+	for level := 0; path != ""; level++ {
+		if level > 15 { return nil, fmt.Errorf("Bags cannot be nested more than 16 deep") }
+		if level > 0 {
+			if !strings.HasPrefix(path, ".") { return nil, fmt.Errorf("Expected . before %v", path) }
+			path = path[1:]
+		}
+
+		switch {
+		case strings.HasPrefix(path, "["):
+			end := strings.Index(path, "]")
+			if end < 0 { return nil, fmt.Errorf("Unterminated index %v", path) }
+			index, err := strconv.ParseUint(path[1:end], 10, 64)
+			if err != nil { return nil, err }
+			headers, path = append(headers, MakeIndexedHeader(level, index)), path[end+1:]
+		case strings.HasPrefix(path, "\""):
+			quoted, err := strconv.QuotedPrefix(path)
+			if err != nil { return nil, err }
+			key, _ := strconv.Unquote(quoted)
+			headers, path = append(headers, MakeKeyedHeader(level, []byte(key))), path[len(quoted):]
+		default:
+			return nil, fmt.Errorf("Expected [index] or \"key\" but found %v", path)
+		}
+	}
+	if len(headers) == 0 { return nil, fmt.Errorf("Missing bag index or key") }
+	return headers, nil
+}
+
+// Parses a header line, returning the header atom of the last segment of its path
+func parseHeader(line string) (atomlayer.Atom, error) {
+	path, err := ParsePath(line)
+	if err != nil { return nil, err }
+	return path[len(path)-1], nil
+}
+
+func parseQuoted(quoted string) (atomlayer.Atom, error) {
+	payload, err := strconv.Unquote(quoted)
+	if err != nil { return nil, err }
+	return MakeDataAtom([]byte(payload)), nil
+}
+
+func parseHex(text string) (atomlayer.Atom, error) {
+	if !strings.HasPrefix(text, "0x") { return nil, fmt.Errorf("Expected hex beginning with 0x") }
+	payload, err := hex.DecodeString(text[2:])
+	if err != nil { return nil, err }
+	return atomlayer.Atom(payload), nil
+}
+
+func invalidTextLine(number int, line string, err error) error {
+	return fmt.Errorf("Invalid atom on line %v %q: %v", number, line, err)
+}
@@ -0,0 +1,96 @@
+package baggageprotocol
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+func TestFormatText(t *testing.T) {
+	baggage := atoms(
+		header(0, 2),
+			header(1, 0),
+				data(0, 0, 0, 55),
+			keyed(1, "key"),
+				data('h', 'i'),
+				data(),
+				[]byte{},
+		header(0, 300),
+	)
+
+	expected := `bag[2]
+  bag[2].[0]
+    0x00000037
+  bag[2]."key"
+    "hi"
+    0x
+    <trim>
+bag[300]
+`
+	assert.Equal(t, expected, FormatText(baggage))
+
+	parsed, err := ParseText(expected)
+	assert.Nil(t, err)
+	assert.Equal(t, baggage, parsed)
+}
+
+func TestFormatTextRaw(t *testing.T) {
+	baggage := atoms(
+		[]byte{},
+		header(1, 3),				// Jumps a level
+		header(0, 1),
+			[]byte{0x05, 1},		// Data atom with an unknown prefix
+			[]byte{0xf8, 0xff},		// Malformed index
+	)
+
+	text := FormatText(baggage)
+	assert.Equal(t, "<trim>\nraw 0xf003\nbag[1]\n  raw 0x0501\n  raw 0xf8ff\n", text)
+
+	parsed, err := ParseText(text)
+	assert.Nil(t, err)
+	assert.Equal(t, baggage, parsed)
+}
+
+func TestParseText(t *testing.T) {
+	text := `
+		# Comments and indentation are ignored
+		bag"root"
+		bag[5]
+		  bag[5].[1]."nested"
+		    "quoted \"string\""
+	`
+	parsed, err := ParseText(text)
+	assert.Nil(t, err)
+	assert.Equal(t, atoms(keyed(0, "root"), header(0, 5), keyed(2, "nested"), data([]byte(`quoted "string"`)...)), parsed)
+
+	for _, invalid := range []string{"bag", "bag[x]", "bag[1", "bag[1][2]", "bag\"key", "0xzz", "\"unterminated", "raw ff"} {
+		_, err = ParseText(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	baggage := []atomlayer.Atom{header(0, 7), data(0xff, 0x00), keyed(1, "\x00\n"), data('\n')}
+	parsed, err := ParseText(FormatText(baggage))
+	assert.Nil(t, err)
+	assert.Equal(t, baggage, parsed)
+}
+
+func TestParseTextTooDeep(t *testing.T) {
+	_, err := ParseText("bag[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0].[0]")
+	assert.NotNil(t, err)
+}
+
+func TestFormatPath(t *testing.T) {
+	assert.Equal(t, "bag[2].\"key\".[0]", FormatPath(atoms(header(0, 2), keyed(1, "key"), header(2, 0))))
+	assert.Equal(t, "bag[2].<0xf0ff>", FormatPath(atoms(header(0, 2), []byte{0xf0, 0xff})))
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath("bag[2].\"key\".[0]")
+	assert.Nil(t, err)
+	assert.Equal(t, atoms(header(0, 2), keyed(1, "key"), header(2, 0)), path)
+
+	_, err = ParsePath("[2]")
+	assert.NotNil(t, err)
+}
@@ -28,10 +28,77 @@ func find(atoms []atomlayer.Atom, startat int, target atomlayer.Atom) (exists bo
 	return
 }
 
-// Gets the fully qualified path to the overflow marker, if it exists in the atoms
-func overflowPath(atoms []atomlayer.Atom) []atomlayer.Atom {
-	// TODO
-	return nil
+// Gets the fully qualified paths of the bags containing overflow markers, if any exist in the atoms.  Each path is the
+// sequence of header atoms leading to the bag that contains the marker.  An empty path indicates a marker preceding
+// all bags, which means that entire bags may have been dropped.
+func OverflowPaths(atoms []atomlayer.Atom) [][]atomlayer.Atom {
+	var tracker overflowTracker
+	for _, atom := range atoms {
+		tracker.visit(atom)
+	}
+	return tracker.paths
+}
+
+// Returns true if bags absent from the atoms may have been dropped entirely.  There are two ways to mark this: a trim
+// marker preceding all bags, which is where TrimWithPolicy places it when it drops bags, or a trim marker as the very
+// last atom, which is where atomlayer.Trim places it when it cuts the tail of the baggage.  The latter only accounts
+// for bags that would follow all of the atoms; see Open.  Other trim markers, including those that end a root bag in
+// the middle of the baggage, only mean that the bag containing them lost data.
+func MayHaveDroppedBags(atoms []atomlayer.Atom) bool {
+	var tracker overflowTracker
+	for _, atom := range atoms {
+		tracker.visit(atom)
+	}
+	return tracker.mayHaveDroppedBags(true)
+}
+
+// Tracks the fully qualified path of the bag containing each atom visited, recording the paths of any trim markers
+type overflowTracker struct {
+	path    []atomlayer.Atom
+	paths   [][]atomlayer.Atom
+	leading bool // True if a trim marker preceded all bags
+	marker  bool // True if the last atom visited was a trim marker
+}
+
+func (t *overflowTracker) visit(atom atomlayer.Atom) {
+	t.marker = atomlayer.IsTrimMarker(atom)
+	switch {
+	case t.marker:
+		t.paths = addPath(t.paths, append([]atomlayer.Atom(nil), t.path...))
+		t.leading = t.leading || len(t.path) == 0
+	case IsHeader(atom):
+		t.path = enterHeader(t.path, atom)
+	}
+}
+
+// Returns true if a bag following the atoms visited may have been dropped, given whether any atoms follow the bag;
+// see MayHaveDroppedBags
+func (t *overflowTracker) mayHaveDroppedBags(last bool) bool {
+	return t.leading || last && t.marker
+}
+
+// Returns the path of the bag with the provided header, given the path of the bag containing the previous atom.  May
+// modify path in place.
+func enterHeader(path []atomlayer.Atom, header atomlayer.Atom) []atomlayer.Atom {
+	level, err := HeaderLevel(header)
+	if err != nil || level > len(path) { level = len(path) }	// Nest invalid level jumps under the current path
+	return append(path[:level], header)
+}
+
+// Adds path to paths, unless it's already present
+func addPath(paths [][]atomlayer.Atom, path []atomlayer.Atom) [][]atomlayer.Atom {
+	for _, existing := range paths {
+		if equalPaths(existing, path) { return paths }
+	}
+	return append(paths, path)
+}
+
+func equalPaths(a, b []atomlayer.Atom) bool {
+	if len(a) != len(b) { return false }
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) { return false }
+	}
+	return true
 }
 
 // Enum specifies what to do with overflow markers if they exist in a bag about to be dropped
@@ -43,20 +110,101 @@ const (
 	PushMarkerDown								// Push the marker down to the root
 )
 
+// Returns true if the provided atoms contain the specified root bag
+func HasBag(atoms []atomlayer.Atom, bagIndex uint64) bool {
+	exists, _, _ := find(atoms, 0, MakeIndexedHeader(0, bagIndex))
+	return exists
+}
+
 // Drops the specified bag from the provided atoms.  Can also specify what to do with any overflow markers
-// we find.
+// we find; see DropPath.
 func Drop(atoms []atomlayer.Atom, bagIndex uint64, overflow OverflowMarkerBehavior) []atomlayer.Atom {
+	return DropPath(atoms, []atomlayer.Atom{MakeIndexedHeader(0, bagIndex)}, overflow)
+}
+
+// Replaces the specified bag with the provided atoms, which must be the entire bag starting with its header, eg. as
+// returned by a Writer.  The bag's existing atoms, including any overflow markers, are spliced out, so unlike Drop
+// followed by Merge, the atoms are only copied once.
+func Replace(atoms []atomlayer.Atom, bagIndex uint64, bag []atomlayer.Atom) []atomlayer.Atom {
 	target := MakeIndexedHeader(0, bagIndex)
 	exists, _, i := find(atoms, 0, target)
+	j := i
+	if exists { _, _, j = find(atoms, i+1, target) }
 
-	if !exists { return atoms }
-
-	_,_,j := find(atoms, i+1, target)
-	// TODO: implement choice of overflow behavior; current behavior is Drop
-	var r []atomlayer.Atom
+	r := make([]atomlayer.Atom, 0, len(atoms) - (j-i) + len(bag))
 	r = append(r, atoms[:i]...)
-	r = append(r, atoms[j:]...)
-	return r
+	r = append(r, bag...)
+	return append(r, atoms[j:]...)
+}
+
+// Drops the bag at the specified fully qualified path, along with all of its child bags.  The ancestors of the bag are
+// retained, even if they become empty.  Can also specify what to do with any overflow markers we find.
+func DropPath(atoms []atomlayer.Atom, path []atomlayer.Atom, overflow OverflowMarkerBehavior) []atomlayer.Atom {
+	if len(path) == 0 { return atoms }
+
+	// Find the bag
+	var current []atomlayer.Atom
+	start, end := -1, len(atoms)
+	for i, atom := range atoms {
+		if !IsHeader(atom) { continue }
+		if level, err := HeaderLevel(atom); start >= 0 && err == nil && level < len(path) { end = i; break }
+		current = enterHeader(current, atom)
+		if start < 0 && equalPaths(current, path) { start = i }
+	}
+	if start < 0 { return atoms }
+
+	// Find any overflow markers within the bag
+	dropped := overflowTracker{path: append([]atomlayer.Atom(nil), path[:len(path)-1]...)}
+	for _, atom := range atoms[start:end] { dropped.visit(atom) }
+
+	var retained []atomlayer.Atom
+	switch {
+	case len(dropped.paths) == 0:
+	case overflow == RetainMarkerPosition:
+		for _, markerPath := range dropped.paths {
+			retained = atomlayer.Merge(retained, append(markerPath[len(path)-1:], atomlayer.TrimMarker))
+		}
+	case overflow == PushMarkerDown && (len(atoms) == 0 || !atomlayer.IsTrimMarker(atoms[0])):
+		return append(append([]atomlayer.Atom{atomlayer.TrimMarker}, atoms[:start]...), atoms[end:]...)
+	}
+
+	r := make([]atomlayer.Atom, 0, len(atoms) - (end-start) + len(retained))
+	r = append(r, atoms[:start]...)
+	r = append(r, retained...)
+	return append(r, atoms[end:]...)
+}
+
+// A root bag within a baggage context
+type RootBag struct {
+	Header atomlayer.Atom   // The header atom of the bag
+	Atoms  []atomlayer.Atom // All of the atoms of the bag, starting with its header
+}
+
+// Returns the index of the root bag, or false if it is a keyed bag
+func (bag RootBag) Index() (uint64, bool) {
+	index, err := HeaderIndex(bag.Header)
+	if err != nil || !bytes.Equal(bag.Header, MakeIndexedHeader(0, index)) { return 0, false }
+	return index, true
+}
+
+// Splits atoms into their root bags.  Also returns any atoms, such as trim markers, that precede the first root bag.
+// The returned slices share the provided atoms.
+func SplitRootBags(atoms []atomlayer.Atom) (preamble []atomlayer.Atom, bags []RootBag) {
+	start := -1
+	for i, atom := range atoms {
+		if level, err := HeaderLevel(atom); !IsHeader(atom) || err != nil || level != 0 { continue }
+		switch {
+		case start == -1: preamble = atoms[:i:i]
+		default: bags = append(bags, RootBag{atoms[start], atoms[start:i:i]})
+		}
+		start = i
+	}
+
+	switch {
+	case start == -1: preamble = atoms
+	default: bags = append(bags, RootBag{atoms[start], atoms[start:len(atoms):len(atoms)]})
+	}
+	return
 }
 
 type LexicographicAtomSorter []atomlayer.Atom
@@ -84,6 +84,14 @@ func TestFind(t *testing.T) {
 	assert.Equal(t, 7, i)
 }
 
+func TestHasBag(t *testing.T) {
+	baggage := atoms(header(0, 2), data(1), header(0, 5))
+	assert.True(t, HasBag(baggage, 2))
+	assert.True(t, HasBag(baggage, 5))
+	assert.False(t, HasBag(baggage, 3))
+	assert.False(t, HasBag(nil, 2))
+}
+
 func TestDrop(t *testing.T) {
 	b0 := atoms(header(0, 0), data(5))
 	b1 := atoms(header(0, 2), data(8), []byte{})
@@ -105,4 +113,87 @@ func TestDrop(t *testing.T) {
 
 	test5 := Drop(baggage, 4, DropMarker)
 	assert.Equal(t, append(append([]atomlayer.Atom(nil), b0...), b1...), test5)
-}
\ No newline at end of file
+
+	// Bag 2 contains a trim marker
+	test6 := Drop(baggage, 2, PushMarkerDown)
+	assert.Equal(t, append(append(atoms([]byte{}), b0...), b2...), test6)
+
+	test7 := Drop(baggage, 2, RetainMarkerPosition)
+	assert.Equal(t, append(append(append([]atomlayer.Atom(nil), b0...), header(0, 2), []byte{}), b2...), test7)
+}
+
+func TestReplace(t *testing.T) {
+	b0 := atoms(header(0, 0), data(5))
+	b1 := atoms(header(0, 2), data(8), []byte{})
+	b2 := atoms(header(0, 4), data(8))
+	baggage := append(append(append([]atomlayer.Atom(nil), b0...), b1...), b2...)
+	bag := atoms(header(0, 2), data(9))
+
+	// Replace must match Drop followed by Merge, whether or not the bag exists
+	for _, index := range []uint64{0, 1, 2, 3, 4, 5} {
+		bag := atoms(header(0, index), data(9))
+		assert.Equal(t, atomlayer.Merge(Drop(baggage, index, DropMarker), bag), Replace(baggage, index, bag))
+	}
+	assert.Equal(t, atoms([]byte{}, header(0, 2), data(9)), Replace(atoms([]byte{}), 2, bag))
+	assert.Equal(t, b0, baggage[:2])
+}
+
+func TestSplitRootBags(t *testing.T) {
+	b0 := atoms(header(0, 0), data(5))
+	b1 := atoms(header(0, 2), data(8), header(1, 0), data(3), []byte{})
+	b2 := atoms(keyed(0, "hello"), data(8))
+
+	baggage := append(append(append(atoms([]byte{}), b0...), b1...), b2...)
+	preamble, bags := SplitRootBags(baggage)
+	assert.Equal(t, atoms([]byte{}), preamble)
+	assert.Equal(t, 3, len(bags))
+	assert.Equal(t, b0, bags[0].Atoms)
+	assert.Equal(t, b1, bags[1].Atoms)
+	assert.Equal(t, b2, bags[2].Atoms)
+	assert.Equal(t, atomlayer.Atom(header(0, 2)), bags[1].Header)
+
+	index, indexed := bags[1].Index()
+	assert.True(t, indexed)
+	assert.Equal(t, uint64(2), index)
+	_, indexed = bags[2].Index()
+	assert.False(t, indexed)
+
+	preamble, bags = SplitRootBags(b1)
+	assert.Empty(t, preamble)
+	assert.Equal(t, []RootBag{{b1[0], b1}}, bags)
+
+	preamble, bags = SplitRootBags(atoms(data(1)))
+	assert.Equal(t, atoms(data(1)), preamble)
+	assert.Empty(t, bags)
+}
+
+func TestDropPath(t *testing.T) {
+	baggage := atoms(
+		header(0, 2),
+			data(1),
+			header(1, 0),
+				data(2),
+				header(2, 4),
+					[]byte{},
+			header(1, 1),
+				data(3),
+		header(0, 3),
+			data(4),
+	)
+
+	dropped := DropPath(baggage, atoms(header(0, 2), header(1, 0)), DropMarker)
+	assert.Equal(t, atoms(header(0, 2), data(1), header(1, 1), data(3), header(0, 3), data(4)), dropped)
+
+	dropped = DropPath(baggage, atoms(header(0, 2), header(1, 0)), RetainMarkerPosition)
+	assert.Equal(t, atoms(header(0, 2), data(1), header(1, 0), header(2, 4), []byte{}, header(1, 1), data(3), header(0, 3), data(4)), dropped)
+
+	dropped = DropPath(baggage, atoms(header(0, 2)), PushMarkerDown)
+	assert.Equal(t, atoms([]byte{}, header(0, 3), data(4)), dropped)
+
+	dropped = DropPath(baggage, atoms(header(0, 3)), PushMarkerDown)
+	assert.Equal(t, baggage[:8], dropped)
+
+	assert.Equal(t, baggage, DropPath(baggage, atoms(header(0, 2), header(1, 5)), DropMarker))
+	assert.Equal(t, baggage, DropPath(baggage, atoms(header(1, 0)), DropMarker))
+	assert.Equal(t, baggage, DropPath(baggage, nil, DropMarker))
+}
@@ -0,0 +1,74 @@
+package baggageprotocol
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+// Checks that atoms are well-formed baggage, returning a description of each problem found.  Trim markers are not
+// problems; they can appear anywhere.
+func Validate(atoms []atomlayer.Atom) (problems []error) {
+	type frame struct {
+		lastChild atomlayer.Atom // The header of the most recent child bag
+	}
+	stack := []frame{{}}	// stack[i] is the bag at level i-1; stack[0] is the root
+
+	for i, atom := range atoms {
+		switch level, err := HeaderLevel(atom); {
+		case atomlayer.IsTrimMarker(atom):
+		case IsData(atom) && atom[0] != data_prefix_byte:	problems = append(problems, invalidDataPrefix(i, atom))
+		case IsData(atom) && len(stack) == 1:				problems = append(problems, dataOutsideBag(i, atom))
+		case IsData(atom):
+		case err != nil:									problems = append(problems, err)
+		default:
+			headerLevel := level
+			if level > len(stack)-1 {
+				problems = append(problems, levelJump(i, atom, level, len(stack)-2))
+				level = len(stack)-1
+			}
+			stack = stack[:level+1]
+			parent := &stack[level]
+			if parent.lastChild != nil && bytes.Compare(parent.lastChild, atom) >= 0 {
+				problems = append(problems, outOfOrderHeader(i, atom, parent.lastChild))
+			}
+			parent.lastChild = atom
+			stack = append(stack, frame{})
+
+			switch {
+			case IsKeyedHeader(atom):
+			case !IsIndexedHeader(atom):					problems = append(problems, unknownHeaderType(i, atom))
+			default:
+				index, err := HeaderIndex(atom)
+				if err != nil || !bytes.Equal(atom, MakeIndexedHeader(headerLevel, index)) {
+					problems = append(problems, malformedIndex(i, atom))
+				}
+			}
+		}
+	}
+	return
+}
+
+func invalidDataPrefix(i int, atom atomlayer.Atom) error {
+	return fmt.Errorf("Atom %v: data atom %v has unknown prefix %v", i, atom, atom[0])
+}
+
+func dataOutsideBag(i int, atom atomlayer.Atom) error {
+	return fmt.Errorf("Atom %v: data atom %v precedes all bags", i, atom)
+}
+
+func levelJump(i int, atom atomlayer.Atom, level, parentLevel int) error {
+	return fmt.Errorf("Atom %v: header %v at level %v jumps more than one level below its parent at level %v", i, atom, level, parentLevel)
+}
+
+func outOfOrderHeader(i int, atom, previous atomlayer.Atom) error {
+	return fmt.Errorf("Atom %v: header %v is not after its preceding sibling %v", i, atom, previous)
+}
+
+func unknownHeaderType(i int, atom atomlayer.Atom) error {
+	return fmt.Errorf("Atom %v: header %v is neither indexed nor keyed", i, atom)
+}
+
+func malformedIndex(i int, atom atomlayer.Atom) error {
+	return fmt.Errorf("Atom %v: indexed header %v has a malformed index", i, atom)
+}
@@ -0,0 +1,44 @@
+package baggageprotocol
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+func TestValidate(t *testing.T) {
+	baggage := atoms(
+		[]byte{},
+		header(0, 2),
+			data(1),
+			[]byte{},
+			header(1, 0),
+				data(2),
+			keyed(1, "key"),
+				header(2, 7),
+		header(0, 5),
+			[]byte{},
+	)
+	assert.Empty(t, Validate(baggage))
+	assert.Empty(t, Validate(nil))
+}
+
+func TestValidateProblems(t *testing.T) {
+	cases := []struct{
+		name  string
+		atoms []atomlayer.Atom
+	}{
+		{"data prefix", atoms(header(0, 1), []byte{5, 1})},
+		{"data outside bag", atoms(data(1), header(0, 1))},
+		{"level jump", atoms(header(0, 1), header(2, 0))},
+		{"out of order", atoms(header(0, 2), header(0, 1))},
+		{"duplicate", atoms(header(0, 2), header(1, 0), header(1, 0))},
+		{"keys before indices", atoms(header(0, 2), keyed(1, "a"), header(1, 0))},
+		{"unknown header type", atoms([]byte{0xf9})},
+		{"malformed index", atoms([]byte{0xf8, 0xff})},
+		{"non-canonical index", atoms([]byte{0xf8, 0x80, 0x01})},
+	}
+	for _, c := range cases {
+		assert.Equal(t, 1, len(Validate(c.atoms)), c.name)
+	}
+}
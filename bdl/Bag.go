@@ -10,4 +10,11 @@ type Bag interface {
 	Write(w *baggageprotocol.Writer)
 	SetUnprocessedAtoms(atoms []atomlayer.Atom)
 	GetUnprocessedAtoms() []atomlayer.Atom
-}
\ No newline at end of file
+}
+
+// Implemented by bags that can be deep copied.  BaggageContext can only cache decoded bags that implement Clone, since
+// a cached bag must not share maps or slices with the bags handed out to callers.
+type CloneableBag interface {
+	Bag
+	Clone() Bag // Returns a copy of the bag, of the same type, that shares no mutable state with the original
+}
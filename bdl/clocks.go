@@ -0,0 +1,161 @@
+package bdl
+
+import (
+	"encoding/binary"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+)
+
+// Logical clocks for tracking causality between events in different components.  Components are identified by the
+// baggage's ComponentID, which is unique to each branch of the baggage.  Clocks are mergeable types, as described in
+// sketches.go, and merging them takes the maximum:
+//
+//   VectorClock    each component's counter is one atom, [component ID][counter].  Counters of the same component
+//                  take the maximum when read
+//   LamportClock   the time as a max register
+
+// The causal relationship between two clocks
+type Causality int
+
+const (
+	Identical      Causality = iota // The clocks are equal
+	HappenedBefore                  // The first clock is causally before the second
+	HappenedAfter                   // The first clock is causally after the second
+	Concurrent                      // Neither clock is before the other
+)
+
+func (causality Causality) String() string {
+	switch causality {
+	case Identical:			return "identical"
+	case HappenedBefore:	return "happened before"
+	case HappenedAfter:		return "happened after"
+	default:				return "concurrent"
+	}
+}
+
+// A vector clock, with a counter for each component.  The zero value is an empty clock
+type VectorClock struct {
+	counters map[uint32]uint64
+}
+
+// Records an event on the given component, returning its new counter
+func (clock *VectorClock) Increment(component uint32) uint64 {
+	if clock.counters == nil { clock.counters = make(map[uint32]uint64) }
+	clock.counters[component]++
+	return clock.counters[component]
+}
+
+// Returns the number of events that the clock has seen on the given component
+func (clock *VectorClock) Get(component uint32) uint64 {
+	return clock.counters[component]
+}
+
+// Returns the IDs of the components that the clock has seen events on
+func (clock *VectorClock) Components() (components []uint32) {
+	for component := range clock.counters {
+		components = append(components, component)
+	}
+	return
+}
+
+// Takes the pointwise maximum of this clock and other
+func (clock *VectorClock) Merge(other *VectorClock) {
+	for component, counter := range other.counters {
+		clock.witness(component, counter)
+	}
+}
+
+func (clock *VectorClock) witness(component uint32, counter uint64) {
+	if clock.counters == nil { clock.counters = make(map[uint32]uint64) }
+	if counter > clock.counters[component] { clock.counters[component] = counter }
+}
+
+// Returns the causal relationship of this clock to other
+func (clock *VectorClock) Compare(other *VectorClock) Causality {
+	less, greater := false, false
+	for component, counter := range clock.counters {
+		if counter > other.counters[component] { greater = true }
+	}
+	for component, counter := range other.counters {
+		if counter > clock.counters[component] { less = true }
+	}
+
+	switch {
+	case less && greater:	return Concurrent
+	case less:				return HappenedBefore
+	case greater:			return HappenedAfter
+	default:				return Identical
+	}
+}
+
+func (clock *VectorClock) Before(other *VectorClock) bool {
+	return clock.Compare(other) == HappenedBefore
+}
+
+func (clock *VectorClock) After(other *VectorClock) bool {
+	return clock.Compare(other) == HappenedAfter
+}
+
+func (clock *VectorClock) ConcurrentWith(other *VectorClock) bool {
+	return clock.Compare(other) == Concurrent
+}
+
+func (clock *VectorClock) Clone() *VectorClock {
+	clone := &VectorClock{}
+	clone.Merge(clock)
+	return clone
+}
+
+func (clock *VectorClock) Read(r *baggageprotocol.Reader) {
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		if len(payload) <= 4 { continue }
+		counter := ReadLexVarUint64(payload[4:])
+		if counter != nil { clock.witness(binary.BigEndian.Uint32(payload), *counter) }
+	}
+}
+
+func (clock *VectorClock) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for component, counter := range clock.counters {
+		if counter != 0 { payloads = append(payloads, append(WriteUint32Fixed(component), WriteLexVarUint64(counter)...)) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// A Lamport clock.  If one event happened before another, its time is less, but unlike vector clocks, the converse
+// does not hold, so Lamport clocks cannot detect concurrent events.  The zero value is time 0
+type LamportClock struct {
+	time uint64
+}
+
+// Records an event, returning its time
+func (clock *LamportClock) Tick() uint64 {
+	clock.time++
+	return clock.time
+}
+
+func (clock *LamportClock) Time() uint64 {
+	return clock.time
+}
+
+// Takes the maximum of this clock and other
+func (clock *LamportClock) Merge(other *LamportClock) {
+	if other.time > clock.time { clock.time = other.time }
+}
+
+// Returns HappenedBefore or HappenedAfter if the clocks' times differ, and Identical otherwise.  Events with different
+// times may still be concurrent
+func (clock *LamportClock) Compare(other *LamportClock) Causality {
+	switch {
+	case clock.time < other.time:	return HappenedBefore
+	case clock.time > other.time:	return HappenedAfter
+	default:						return Identical
+	}
+}
+
+func (clock *LamportClock) Read(r *baggageprotocol.Reader) {
+	if time := ReadMaxUint64(r.Next()); time != nil && *time > clock.time { clock.time = *time }
+}
+
+func (clock *LamportClock) Write(w *baggageprotocol.Writer) {
+	w.Write(WriteMaxUint64(clock.time))
+}
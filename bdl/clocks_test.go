@@ -0,0 +1,43 @@
+package bdl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVectorClock(t *testing.T) {
+	var a, b VectorClock
+	assert.Equal(t, Identical, a.Compare(&b))
+
+	assert.Equal(t, uint64(1), a.Increment(1))
+	assert.Equal(t, uint64(2), a.Increment(1))
+	assert.True(t, b.Before(&a))
+	assert.True(t, a.After(&b))
+
+	b.Increment(2)
+	assert.True(t, a.ConcurrentWith(&b))
+	assert.Equal(t, "concurrent", b.Compare(&a).String())
+
+	c := a.Clone()
+	c.Merge(&b)
+	assert.Equal(t, uint64(2), c.Get(1))
+	assert.Equal(t, uint64(1), c.Get(2))
+	assert.ElementsMatch(t, []uint32{1, 2}, c.Components())
+	assert.True(t, a.Before(c))
+	assert.True(t, b.Before(c))
+	assert.Equal(t, uint64(0), a.Get(2))
+}
+
+func TestLamportClock(t *testing.T) {
+	var a, b LamportClock
+	assert.Equal(t, Identical, a.Compare(&b))
+	assert.Equal(t, uint64(1), a.Tick())
+	assert.Equal(t, HappenedAfter, a.Compare(&b))
+	assert.Equal(t, HappenedBefore, b.Compare(&a))
+
+	b.Tick()
+	b.Tick()
+	a.Merge(&b)
+	assert.Equal(t, uint64(2), a.Time())
+	assert.Equal(t, uint64(3), a.Tick())
+}
@@ -0,0 +1,451 @@
+package bdl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+// Until the BDL compiler generates Go, bags can be written as plain structs whose fields are annotated with their
+// index and encoding, and encoded by reflection:
+//
+//   type XTrace struct {
+//   	TaskID         *int64             `bdl:"0,sfixed64"`
+//   	ParentEventIDs map[int64]struct{} `bdl:"1,set,sfixed64"`
+//   	bdl.BagState
+//   }
+//
+//   func (x *XTrace) Read(r *baggageprotocol.Reader) { bdl.ReadStruct(r, x) }
+//   func (x *XTrace) Write(w *baggageprotocol.Writer) { bdl.WriteStruct(w, x) }
+//   func (x *XTrace) Clone() bdl.Bag { return bdl.CloneStruct(x).(*XTrace) }
+//
+// The embedded BagState preserves unknown atoms and tracks overflow, and provides the remaining methods of Bag.  It
+// can't provide Clone, which must return the embedding type, so structs that omit Clone are not CloneableBags and
+// BaggageContext won't cache them.  Tags have the form "index,kind[,encoding]", where kind is one of:
+//
+//   <scalar>                     a single value, eg. bdl:"0,fixed64".  The field is either a pointer, which is nil if
+//                                the bag is absent, or a value, which is omitted when written if it is the zero value
+//   set,<scalar>                 a set of values, as a map[T]struct{} or map[T]bool
+//   map[,<scalar>]               a map from string keys to values, each in a keyed child bag.  Values default to string
+//   bag                          a nested struct, or pointer to a struct, whose fields are tagged in the same way
+//   min,<varint>, max,<varint>   a register that keeps the smallest or largest value when branches are merged
+//   fww, lww                     a first-write-wins or last-write-wins Register
+//
+// and scalar encodings are fixed32, sfixed32, fixed64, sfixed64 (any 32 or 64 bit integer); uint32, uint64, int32,
+// int64 (lexicographic varints of the matching type); bool; taint; string; and bytes.  See registers.go for how
+// registers are encoded.  Fields without a bdl tag, or tagged "-", are ignored.  Malformed tags are programming errors,
+// so ReadStruct and WriteStruct panic; use CheckStruct to detect them in tests.
+
+// Embedded in structs that are read by ReadStruct and written by WriteStruct
+type BagState struct {
+	unknown    []atomlayer.Atom // Atoms that aren't part of the struct, but were present
+	overflowed bool             // True if the bag lost data due to trimming
+}
+
+func (state *BagState) SetUnprocessedAtoms(atoms []atomlayer.Atom) { state.unknown = atoms }
+func (state *BagState) GetUnprocessedAtoms() []atomlayer.Atom { return state.unknown }
+
+// Returns true if the bag lost data due to trimming when it was read
+func (state *BagState) Overflowed() bool { return state.overflowed }
+
+func (state *BagState) bagState() *BagState { return state }
+
+// Implemented by structs that embed BagState
+type hasBagState interface {
+	bagState() *BagState
+}
+
+// Reads the fields of the struct pointed to by v from the bag being read.  Panics if v's tags are malformed.
+func ReadStruct(r *baggageprotocol.Reader, v interface{}) {
+	value, codec := structValue(v)
+	codec.read(r, value)
+	if state, ok := v.(hasBagState); ok { state.bagState().overflowed = r.Overflowed }
+}
+
+// Writes the fields of the struct pointed to by v to the bag being written.  Panics if v's tags are malformed.
+func WriteStruct(w *baggageprotocol.Writer, v interface{}) {
+	value, codec := structValue(v)
+	codec.write(w, value)
+	if state, ok := v.(hasBagState); ok && state.bagState().overflowed { w.MarkOverflow() }
+}
+
+// Returns a pointer to a deep copy of the struct pointed to by v.  Tagged fields, including nested bags, and the
+// BagState share no maps, pointers or slices with v; untagged fields are copied shallowly.  Panics if v's tags are
+// malformed.
+func CloneStruct(v interface{}) interface{} {
+	value, codec := structValue(v)
+	clone := reflect.New(value.Type())
+	codec.clone(clone.Elem(), value)
+	return clone.Interface()
+}
+
+// Returns an error if v is not a pointer to a struct with well-formed bdl tags
+func CheckStruct(v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct { return notStructPointer(t) }
+	_, err := codecFor(t.Elem())
+	return err
+}
+
+func structValue(v interface{}) (reflect.Value, *structCodec) {
+	if err := CheckStruct(v); err != nil { panic(err) }
+	value := reflect.ValueOf(v).Elem()
+	codec, _ := codecFor(value.Type())
+	return value, codec
+}
+
+// The fields of a struct type, in order of index
+type structCodec struct {
+	fields []fieldCodec
+}
+
+type fieldKind int
+const (
+	scalarField fieldKind = iota
+	setField
+	mapField
+	bagField
+)
+
+type fieldCodec struct {
+	name   string
+	index  uint64
+	field  int           // Index of the field within the struct
+	kind   fieldKind
+	scalar scalarCodec   // Encoding of scalars, set elements and map values
+	nested *structCodec  // Fields of nested bags
+}
+
+// Reads a single encoded value into v, returning false if the payload is malformed
+type scalarCodec struct {
+	read  func(payload []byte, v reflect.Value) bool
+	write func(v reflect.Value) []byte
+}
+
+var codecs sync.Map // reflect.Type -> *structCodec, for types whose tags are well-formed
+
+func codecFor(t reflect.Type) (*structCodec, error) {
+	if codec, exists := codecs.Load(t); exists { return codec.(*structCodec), nil }
+	codec, err := newStructCodec(t, make(map[reflect.Type]*structCodec))
+	if err != nil { return nil, err }
+	codecs.Store(t, codec)
+	return codec, nil
+}
+
+// Builds the codec for a struct type.  Codecs under construction are in building, so that recursive types terminate.
+func newStructCodec(t reflect.Type, building map[reflect.Type]*structCodec) (*structCodec, error) {
+	if codec, exists := building[t]; exists { return codec, nil }
+	codec := &structCodec{}
+	building[t] = codec
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup("bdl")
+		if !tagged || tag == "-" { continue }
+		if !field.IsExported() { return nil, invalidTag(t, field, "field is not exported") }
+
+		f, err := newFieldCodec(field, tag, building)
+		if err != nil { return nil, invalidTag(t, field, err.Error()) }
+		f.field = i
+		codec.fields = append(codec.fields, f)
+	}
+
+	sort.SliceStable(codec.fields, func(i, j int) bool { return codec.fields[i].index < codec.fields[j].index })
+	for i := 1; i < len(codec.fields); i++ {
+		if codec.fields[i].index == codec.fields[i-1].index { return nil, duplicateIndex(t, codec.fields[i-1].name, codec.fields[i].name) }
+	}
+	return codec, nil
+}
+
+func newFieldCodec(field reflect.StructField, tag string, building map[reflect.Type]*structCodec) (f fieldCodec, err error) {
+	parts := strings.Split(tag, ",")
+	f.name = field.Name
+	if f.index, err = strconv.ParseUint(parts[0], 10, 64); err != nil { return f, fmt.Errorf("Invalid index %q", parts[0]) }
+	if len(parts) < 2 { return f, fmt.Errorf("Missing encoding") }
+
+	t := field.Type
+	switch kind, encoding := parts[1], strings.Join(parts[2:], ","); kind {
+	case "set":
+		f.kind = setField
+		if t.Kind() != reflect.Map || !(t.Elem().Kind() == reflect.Bool || t.Elem() == reflect.TypeOf(struct{}{})) {
+			return f, fmt.Errorf("Sets must be map[T]struct{} or map[T]bool, not %v", t)
+		}
+		f.scalar, err = newScalarCodec(encoding, t.Key())
+	case "map":
+		f.kind = mapField
+		if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String { return f, fmt.Errorf("Maps must have string keys, not %v", t) }
+		if encoding == "" { encoding = "string" }
+		f.scalar, err = newScalarCodec(encoding, t.Elem())
+	case "bag":
+		f.kind = bagField
+		if t.Kind() == reflect.Ptr { t = t.Elem() }
+		if t.Kind() != reflect.Struct { return f, fmt.Errorf("Bags must be a struct or pointer to a struct, not %v", field.Type) }
+		f.nested, err = newStructCodec(t, building)
+	case "min", "max":
+		f.kind = scalarField
+		if !isVarint(encoding) { return f, fmt.Errorf("Registers %v must be uint32, uint64, int32 or int64, not %q", kind, encoding) }
+		if t.Kind() == reflect.Ptr { t = t.Elem() }
+		f.scalar, err = newScalarCodec(encoding, t)
+		if kind == "max" { f.scalar = f.scalar.complemented() }
+	case "fww", "lww":
+		f.kind = scalarField
+		if len(parts) > 2 { return f, fmt.Errorf("Unexpected %q after %v", encoding, kind) }
+		if t.Kind() == reflect.Ptr { t = t.Elem() }
+		if t != reflect.TypeOf(Register{}) { return f, fmt.Errorf("Registers %v must be a Register or *Register, not %v", kind, field.Type) }
+		f.scalar = registerCodec(kind == "lww")
+	default:
+		f.kind = scalarField
+		if len(parts) > 2 { return f, fmt.Errorf("Unexpected %q after scalar encoding", encoding) }
+		if t.Kind() == reflect.Ptr { t = t.Elem() }
+		f.scalar, err = newScalarCodec(kind, t)
+	}
+	return
+}
+
+func newScalarCodec(encoding string, t reflect.Type) (scalarCodec, error) {
+	k := t.Kind()
+	switch {
+	case (encoding == "fixed32" || encoding == "sfixed32") && (k == reflect.Uint32 || k == reflect.Int32):
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadUint32Fixed(p); if x != nil { setBits(v, uint64(*x), 32) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteUint32Fixed(uint32(bits(v))) },
+		}, nil
+	case (encoding == "fixed64" || encoding == "sfixed64") && (k == reflect.Uint64 || k == reflect.Int64):
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadUint64Fixed(p); if x != nil { setBits(v, *x, 64) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteUint64Fixed(bits(v)) },
+		}, nil
+	case encoding == "uint32" && k == reflect.Uint32:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadLexVarUint32(p); if x != nil { v.SetUint(uint64(*x)) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteLexVarUint32(uint32(v.Uint())) },
+		}, nil
+	case encoding == "uint64" && k == reflect.Uint64:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadLexVarUint64(p); if x != nil { v.SetUint(*x) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteLexVarUint64(v.Uint()) },
+		}, nil
+	case encoding == "int32" && k == reflect.Int32:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadLexVarInt32(p); if x != nil { v.SetInt(int64(*x)) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteLexVarInt32(int32(v.Int())) },
+		}, nil
+	case encoding == "int64" && k == reflect.Int64:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadLexVarInt64(p); if x != nil { v.SetInt(*x) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteLexVarInt64(v.Int()) },
+		}, nil
+	case encoding == "bool" && k == reflect.Bool:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadBool(p); if x != nil { v.SetBool(*x) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteBool(v.Bool()) },
+		}, nil
+	case encoding == "taint" && k == reflect.Bool:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { x := ReadTaint(p); if x != nil { v.SetBool(*x) }; return x != nil },
+			func(v reflect.Value) []byte { return WriteTaint(v.Bool()) },
+		}, nil
+	case encoding == "string" && k == reflect.String:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { v.SetString(string(p)); return true },
+			func(v reflect.Value) []byte { return []byte(v.String()) },
+		}, nil
+	case encoding == "bytes" && k == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return scalarCodec{
+			func(p []byte, v reflect.Value) bool { v.SetBytes(append([]byte{}, p...)); return true },
+			func(v reflect.Value) []byte { return v.Bytes() },
+		}, nil
+	}
+	return scalarCodec{}, fmt.Errorf("Cannot encode %v as %q", t, encoding)
+}
+
+// Encodes values as the complement of the underlying encoding, so that they sort in reverse order
+func (codec scalarCodec) complemented() scalarCodec {
+	return scalarCodec{
+		func(p []byte, v reflect.Value) bool { return codec.read(complement(p), v) },
+		func(v reflect.Value) []byte { return complementInPlace(codec.write(v)) },
+	}
+}
+
+func registerCodec(latestFirst bool) scalarCodec {
+	return scalarCodec{
+		func(p []byte, v reflect.Value) bool { x := readRegister(p, latestFirst); if x != nil { v.Set(reflect.ValueOf(*x)) }; return x != nil },
+		func(v reflect.Value) []byte { return writeRegister(v.Interface().(Register), latestFirst) },
+	}
+}
+
+// Lexicographic varints sort in numeric order and no encoding is a prefix of another, so they can be complemented
+func isVarint(encoding string) bool {
+	return encoding == "uint32" || encoding == "uint64" || encoding == "int32" || encoding == "int64"
+}
+
+// Sets an integer of any signedness from its two's complement bits
+func setBits(v reflect.Value, x uint64, size int) {
+	switch {
+	case v.Kind() == reflect.Uint32 || v.Kind() == reflect.Uint64:	v.SetUint(x)
+	case size == 32:												v.SetInt(int64(int32(x)))
+	default:														v.SetInt(int64(x))
+	}
+}
+
+// Returns the two's complement bits of an integer of any signedness
+func bits(v reflect.Value) uint64 {
+	if v.Kind() == reflect.Uint32 || v.Kind() == reflect.Uint64 { return v.Uint() }
+	return uint64(v.Int())
+}
+
+func (codec *structCodec) read(r *baggageprotocol.Reader, v reflect.Value) {
+	for _, f := range codec.fields {
+		if !r.EnterIndexed(f.index) { continue }
+		f.read(r, v.Field(f.field))
+		r.Exit()
+	}
+}
+
+func (f *fieldCodec) read(r *baggageprotocol.Reader, v reflect.Value) {
+	switch f.kind {
+	case scalarField:
+		payload := r.Next()
+		if payload == nil { return }
+		value := reflect.New(indirect(v.Type())).Elem()
+		if !f.scalar.read(payload, value) { return }
+		if v.Kind() == reflect.Ptr { v.Set(value.Addr()) } else { v.Set(value) }
+
+	case setField:
+		set, present := reflect.MakeMap(v.Type()), reflect.New(v.Type().Elem()).Elem()
+		if present.Kind() == reflect.Bool { present.SetBool(true) }
+		for payload := r.Next(); payload != nil; payload = r.Next() {
+			element := reflect.New(v.Type().Key()).Elem()
+			if f.scalar.read(payload, element) { set.SetMapIndex(element, present) }
+		}
+		v.Set(set)
+
+	case mapField:
+		m := reflect.MakeMap(v.Type())
+		for header := r.Enter(); header != nil; header = r.Enter() {
+			if payload := r.Next(); payload != nil && baggageprotocol.IsKeyedHeader(header) {
+				value := reflect.New(v.Type().Elem()).Elem()
+				if f.scalar.read(payload, value) { m.SetMapIndex(reflect.ValueOf(string(header[1:])).Convert(v.Type().Key()), value) }
+			}
+			r.Exit()
+		}
+		v.Set(m)
+
+	case bagField:
+		if v.Kind() == reflect.Ptr {
+			value := reflect.New(v.Type().Elem())
+			f.nested.read(r, value.Elem())
+			v.Set(value)
+		} else {
+			f.nested.read(r, v)
+		}
+	}
+}
+
+func (codec *structCodec) write(w *baggageprotocol.Writer, v reflect.Value) {
+	for _, f := range codec.fields {
+		field := v.Field(f.field)
+		if field.IsZero() { continue }
+		if field.Kind() == reflect.Ptr { field = field.Elem() }
+		if field.Kind() == reflect.Map && field.Len() == 0 { continue }
+
+		w.Enter(f.index)
+		f.write(w, field)
+		w.Exit()
+	}
+}
+
+func (f *fieldCodec) write(w *baggageprotocol.Writer, v reflect.Value) {
+	switch f.kind {
+	case scalarField:
+		w.Write(f.scalar.write(v))
+
+	case setField:
+		var payloads [][]byte
+		for iter := v.MapRange(); iter.Next(); {
+			if iter.Value().Kind() != reflect.Bool || iter.Value().Bool() { payloads = append(payloads, f.scalar.write(iter.Key())) }
+		}
+		w.WriteSorted(payloads...)
+
+	case mapField:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			w.EnterKey([]byte(key.String()))
+			w.Write(f.scalar.write(v.MapIndex(key)))
+			w.Exit()
+		}
+
+	case bagField:
+		f.nested.write(w, v)
+	}
+}
+
+// Copies src into dst, which must be addressable
+func (codec *structCodec) clone(dst, src reflect.Value) {
+	dst.Set(src)
+	for _, f := range codec.fields { f.clone(dst.Field(f.field), src.Field(f.field)) }
+
+	// Unknown atoms are never modified in place, but appending to a shared slice could overwrite the original's
+	if state, ok := dst.Addr().Interface().(hasBagState); ok {
+		unknown := state.bagState().unknown
+		state.bagState().unknown = unknown[:len(unknown):len(unknown)]
+	}
+}
+
+// Replaces the shallow copy of the field in dst with a deep copy of src
+func (f *fieldCodec) clone(dst, src reflect.Value) {
+	if src.IsZero() { return }
+	if src.Kind() == reflect.Ptr {
+		dst.Set(reflect.New(src.Type().Elem()))
+		dst, src = dst.Elem(), src.Elem()
+	}
+
+	switch f.kind {
+	case scalarField:
+		dst.Set(cloneScalar(src))
+
+	case setField, mapField:
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for iter := src.MapRange(); iter.Next(); { m.SetMapIndex(iter.Key(), cloneScalar(iter.Value())) }
+		dst.Set(m)
+
+	case bagField:
+		f.nested.clone(dst, src)
+	}
+}
+
+// Byte slices and the values of registers are the only scalars that share memory
+func cloneScalar(v reflect.Value) reflect.Value {
+	switch {
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		return reflect.ValueOf(append([]byte{}, v.Bytes()...)).Convert(v.Type())
+	case v.Type() == reflect.TypeOf(Register{}):
+		register := v.Interface().(Register)
+		if register.Value != nil { register.Value = append([]byte{}, register.Value...) }
+		return reflect.ValueOf(register)
+	}
+	return v
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr { return t.Elem() }
+	return t
+}
+
+func notStructPointer(t reflect.Type) error {
+	return fmt.Errorf("Expected a pointer to a struct, not %v", t)
+}
+
+func invalidTag(t reflect.Type, field reflect.StructField, reason string) error {
+	return fmt.Errorf("Invalid bdl tag %q on %v.%v: %v", field.Tag.Get("bdl"), t, field.Name, reason)
+}
+
+func duplicateIndex(t reflect.Type, a, b string) error {
+	return fmt.Errorf("Fields %v and %v of %v have the same bdl index", a, b, t)
+}
@@ -0,0 +1,197 @@
+package bdl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"testing"
+)
+
+type taggedChild struct {
+	Name  string `bdl:"0,string"`
+	Count uint64 `bdl:"1,uint64"`
+}
+
+type taggedBag struct {
+	ID       *int64              `bdl:"0,sfixed64"`
+	Small    int32               `bdl:"1,fixed32"`
+	Flag     *bool               `bdl:"2,taint"`
+	Tags     map[string]string   `bdl:"4,map"`
+	Counts   map[string]int64    `bdl:"5,map,int64"`
+	IDs      map[uint64]struct{} `bdl:"6,set,fixed64"`
+	Names    map[string]bool     `bdl:"7,set,string"`
+	Child    *taggedChild        `bdl:"8,bag"`
+	Payload  []byte              `bdl:"3,bytes"`
+	Ignored  string
+	Excluded string              `bdl:"-"`
+	BagState
+}
+
+func (bag *taggedBag) Read(r *baggageprotocol.Reader) { ReadStruct(r, bag) }
+func (bag *taggedBag) Write(w *baggageprotocol.Writer) { WriteStruct(w, bag) }
+
+func writeTagged(t *testing.T, bag Bag) []atomlayer.Atom {
+	w := baggageprotocol.WriteBag(1)
+	bag.Write(w)
+	w.AddUnprocessedAtoms(bag.GetUnprocessedAtoms())
+	atoms, err := w.Atoms()
+	assert.Nil(t, err)
+	return atoms
+}
+
+func readTagged(t *testing.T, atoms []atomlayer.Atom, bag Bag) {
+	r := baggageprotocol.Open(atoms, 1)
+	bag.Read(r)
+	r.Close()
+	bag.SetUnprocessedAtoms(r.Skipped)
+	assert.Nil(t, r.Err)
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	id, flag := int64(-5), true
+	bag := taggedBag{
+		ID: &id, Small: -3, Flag: &flag, Payload: []byte{1, 2},
+		Tags: map[string]string{"b": "2", "a": "1"},
+		Counts: map[string]int64{"x": -7},
+		IDs: map[uint64]struct{}{9: {}, 4: {}},
+		Names: map[string]bool{"yes": true, "no": false},
+		Child: &taggedChild{Name: "child", Count: 300},
+		Ignored: "ignored", Excluded: "excluded",
+	}
+	atoms := writeTagged(t, &bag)
+
+	var decoded taggedBag
+	readTagged(t, atoms, &decoded)
+	assert.Equal(t, id, *decoded.ID)
+	assert.Equal(t, int32(-3), decoded.Small)
+	assert.True(t, *decoded.Flag)
+	assert.Equal(t, []byte{1, 2}, decoded.Payload)
+	assert.Equal(t, bag.Tags, decoded.Tags)
+	assert.Equal(t, bag.Counts, decoded.Counts)
+	assert.Equal(t, bag.IDs, decoded.IDs)
+	assert.Equal(t, map[string]bool{"yes": true}, decoded.Names)
+	assert.Equal(t, bag.Child, decoded.Child)
+	assert.Equal(t, "", decoded.Ignored)
+	assert.Equal(t, "", decoded.Excluded)
+	assert.Empty(t, decoded.GetUnprocessedAtoms())
+	assert.False(t, decoded.Overflowed())
+
+	assert.Equal(t, atoms, writeTagged(t, &decoded))
+}
+
+func TestStructUnknownAndOverflow(t *testing.T) {
+	atoms := []atomlayer.Atom{
+		baggageprotocol.MakeIndexedHeader(0, 1),
+		baggageprotocol.MakeIndexedHeader(1, 1), WriteUint32Fixed(7),
+		baggageprotocol.MakeIndexedHeader(1, 20), baggageprotocol.MakeDataAtom([]byte("unknown")), atomlayer.TrimMarker,
+	}
+	atoms[2] = baggageprotocol.MakeDataAtom(atoms[2])
+
+	var bag taggedBag
+	readTagged(t, atoms, &bag)
+	assert.Equal(t, int32(7), bag.Small)
+	assert.True(t, bag.Overflowed())
+	assert.Equal(t, atoms[3:], bag.GetUnprocessedAtoms())
+
+	// As with generated bags, the overflow is also marked in the bag itself when it is written back
+	expected := append(append(append([]atomlayer.Atom(nil), atoms[:3]...), atomlayer.TrimMarker), atoms[3:]...)
+	assert.Equal(t, expected, writeTagged(t, &bag))
+}
+
+func TestStructMalformedPayload(t *testing.T) {
+	atoms := []atomlayer.Atom{
+		baggageprotocol.MakeIndexedHeader(0, 1),
+		baggageprotocol.MakeIndexedHeader(1, 0), baggageprotocol.MakeDataAtom([]byte{1, 2, 3}),
+	}
+	var bag taggedBag
+	readTagged(t, atoms, &bag)
+	assert.Nil(t, bag.ID)
+}
+
+func TestCheckStruct(t *testing.T) {
+	assert.Nil(t, CheckStruct(&taggedBag{}))
+	assert.NotNil(t, CheckStruct(taggedBag{}))
+	assert.NotNil(t, CheckStruct(nil))
+
+	for _, invalid := range []interface{}{
+		&struct{ A int64 `bdl:"x,int64"` }{},
+		&struct{ A int64 `bdl:"0"` }{},
+		&struct{ A int64 `bdl:"0,int32"` }{},
+		&struct{ A string `bdl:"0,fixed64"` }{},
+		&struct{ A map[int]string `bdl:"0,map"` }{},
+		&struct{ A map[string]int `bdl:"0,set,int64"` }{},
+		&struct{ A string `bdl:"0,bag"` }{},
+		&struct{ A, B int64 `bdl:"0,int64"` }{},
+		&struct{ a int64 `bdl:"0,int64"` }{},
+		&struct{ A int64 `bdl:"0,max,sfixed64"` }{},
+		&struct{ A string `bdl:"0,min,string"` }{},
+		&struct{ A int64 `bdl:"0,lww"` }{},
+		&struct{ A Register `bdl:"0,fww,bytes"` }{},
+	} {
+		assert.NotNil(t, CheckStruct(invalid))
+		assert.Panics(t, func() { WriteStruct(baggageprotocol.WriteBag(1), invalid) })
+	}
+}
+
+type clonedRegisters struct {
+	Last   Register    `bdl:"0,lww"`
+	First  *Register   `bdl:"1,fww"`
+	Child  taggedChild `bdl:"2,bag"`
+	Tagged *taggedBag  `bdl:"3,bag"`
+}
+
+func TestCloneStruct(t *testing.T) {
+	id, flag := int64(-5), true
+	atoms := writeTagged(t, &taggedBag{
+		ID: &id, Flag: &flag, Payload: []byte{1, 2},
+		Tags: map[string]string{"a": "1"},
+		IDs: map[uint64]struct{}{4: {}},
+		Child: &taggedChild{Name: "child"},
+	})
+	atoms = append(atoms, baggageprotocol.MakeIndexedHeader(1, 20), baggageprotocol.MakeDataAtom([]byte("unknown")))
+	var bag taggedBag
+	readTagged(t, atoms, &bag)
+	bag.Ignored = "ignored"
+
+	clone := CloneStruct(&bag).(*taggedBag)
+	assert.Equal(t, bag, *clone)
+
+	*clone.ID, *clone.Flag, clone.Payload[0] = 1, false, 9
+	clone.Tags["b"], clone.IDs[5], clone.Child.Name = "2", struct{}{}, "changed"
+	clone.SetUnprocessedAtoms(append(clone.GetUnprocessedAtoms(), atomlayer.TrimMarker))
+	assert.Equal(t, int64(-5), *bag.ID)
+	assert.True(t, *bag.Flag)
+	assert.Equal(t, []byte{1, 2}, bag.Payload)
+	assert.Equal(t, map[string]string{"a": "1"}, bag.Tags)
+	assert.Equal(t, map[uint64]struct{}{4: {}}, bag.IDs)
+	assert.Equal(t, "child", bag.Child.Name)
+	assert.Equal(t, atoms[len(atoms)-2:], bag.GetUnprocessedAtoms())
+
+	registers := clonedRegisters{Last: Register{Value: []byte{1}}, First: &Register{Value: []byte{2}}, Tagged: &bag}
+	registersClone := CloneStruct(&registers).(*clonedRegisters)
+	assert.Equal(t, registers, *registersClone)
+	registersClone.Last.Value[0], registersClone.First.Value[0], registersClone.Tagged.Tags["c"] = 0, 0, "3"
+	assert.Equal(t, []byte{1}, registers.Last.Value)
+	assert.Equal(t, []byte{2}, registers.First.Value)
+	assert.Equal(t, map[string]string{"a": "1"}, bag.Tags)
+
+	assert.Panics(t, func() { CloneStruct(bag) })
+}
+
+type recursiveBag struct {
+	Value int64         `bdl:"0,int64"`
+	Next  *recursiveBag `bdl:"1,bag"`
+}
+
+func TestStructRecursive(t *testing.T) {
+	bag := recursiveBag{1, &recursiveBag{2, &recursiveBag{3, nil}}}
+	w := baggageprotocol.WriteBag(1)
+	WriteStruct(w, &bag)
+	atoms, err := w.Atoms()
+	assert.Nil(t, err)
+
+	var decoded recursiveBag
+	r := baggageprotocol.Open(atoms, 1)
+	ReadStruct(r, &decoded)
+	assert.Equal(t, bag, decoded)
+}
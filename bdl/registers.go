@@ -0,0 +1,119 @@
+package bdl
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Registers are single-valued fields with defined conflict resolution.  When two branches set the same field, merging
+// their baggage keeps both data atoms in lexicographic order, and readers take the first.  Registers are encoded so
+// that the first atom is the winner:
+//
+//   min    the value as a lexicographic varint, which sorts in numeric order
+//   max    the complement of the value's lexicographic varint, which sorts in reverse numeric order
+//   fww    first-write-wins: the timestamp and component ID of the write, followed by the value.  The earliest write
+//          sorts first
+//   lww    last-write-wins: the complemented timestamp and component ID, followed by the value.  The latest write
+//          sorts first
+//
+// Writes with the same timestamp are ordered by component ID, then by value, so that every branch picks the same
+// winner.  The losing atoms are superseded, in the sense described for mergeable types in sketches.go.
+
+// A value written at a timestamp by a component, for first-write-wins and last-write-wins registers
+type Register struct {
+	Timestamp int64  // Typically nanoseconds since the Unix epoch
+	Component uint32 // Breaks ties between writes with the same timestamp
+	Value     []byte
+}
+
+// Size of the timestamp and component ID that precede a register's value
+const registerHeaderSize = 12
+
+func ReadMinInt64(bytes []byte) *int64 {
+	return ReadLexVarInt64(bytes)
+}
+
+func WriteMinInt64(v int64) []byte {
+	return WriteLexVarInt64(v)
+}
+
+func ReadMinUint64(bytes []byte) *uint64 {
+	return ReadLexVarUint64(bytes)
+}
+
+func WriteMinUint64(v uint64) []byte {
+	return WriteLexVarUint64(v)
+}
+
+func ReadMaxInt64(bytes []byte) *int64 {
+	return ReadLexVarInt64(complement(bytes))
+}
+
+func WriteMaxInt64(v int64) []byte {
+	return complement(WriteLexVarInt64(v))
+}
+
+func ReadMaxUint64(bytes []byte) *uint64 {
+	return ReadLexVarUint64(complement(bytes))
+}
+
+func WriteMaxUint64(v uint64) []byte {
+	return complement(WriteLexVarUint64(v))
+}
+
+func ReadFirstWriteWins(bytes []byte) *Register {
+	return readRegister(bytes, false)
+}
+
+func WriteFirstWriteWins(register Register) []byte {
+	return writeRegister(register, false)
+}
+
+func ReadLastWriteWins(bytes []byte) *Register {
+	return readRegister(bytes, true)
+}
+
+func WriteLastWriteWins(register Register) []byte {
+	return writeRegister(register, true)
+}
+
+// Returns whichever of a and b wins under first-write-wins; the same winner as merging their encoded atoms
+func FirstWriteWins(a, b Register) Register {
+	if bytes.Compare(WriteFirstWriteWins(b), WriteFirstWriteWins(a)) < 0 { return b }
+	return a
+}
+
+// Returns whichever of a and b wins under last-write-wins; the same winner as merging their encoded atoms
+func LastWriteWins(a, b Register) Register {
+	if bytes.Compare(WriteLastWriteWins(b), WriteLastWriteWins(a)) < 0 { return b }
+	return a
+}
+
+func readRegister(bytes []byte, latestFirst bool) *Register {
+	if len(bytes) < registerHeaderSize { return nil }
+	header := bytes[:registerHeaderSize]
+	if latestFirst { header = complement(header) }
+	return &Register{
+		Timestamp: int64(binary.BigEndian.Uint64(header) ^ (1 << 63)),
+		Component: binary.BigEndian.Uint32(header[8:]),
+		Value:     append([]byte{}, bytes[registerHeaderSize:]...),
+	}
+}
+
+func writeRegister(register Register, latestFirst bool) []byte {
+	bytes := make([]byte, registerHeaderSize, registerHeaderSize + len(register.Value))
+	binary.BigEndian.PutUint64(bytes, uint64(register.Timestamp) ^ (1 << 63))	// Flip the sign bit so that negative timestamps sort first
+	binary.BigEndian.PutUint32(bytes[8:], register.Component)
+	if latestFirst { complementInPlace(bytes) }
+	return append(bytes, register.Value...)
+}
+
+// Returns a copy of bytes with every bit inverted, which reverses the order of prefix-free encodings
+func complement(bytes []byte) []byte {
+	return complementInPlace(append([]byte{}, bytes...))
+}
+
+func complementInPlace(bytes []byte) []byte {
+	for i := range bytes { bytes[i] = ^bytes[i] }
+	return bytes
+}
@@ -0,0 +1,110 @@
+package bdl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"math"
+	"sort"
+	"testing"
+)
+
+// Returns the value whose encoding sorts first
+func firstEncoded(values []int64, encode func(int64) []byte) int64 {
+	sort.Slice(values, func(i, j int) bool { return bytes.Compare(encode(values[i]), encode(values[j])) < 0 })
+	return values[0]
+}
+
+func TestMinMax(t *testing.T) {
+	values := []int64{0, 1, -1, 63, 64, -64, -65, 1 << 20, -(1 << 20), 1 << 40, math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+	for _, v := range values {
+		assert.Equal(t, v, *ReadMinInt64(WriteMinInt64(v)))
+		assert.Equal(t, v, *ReadMaxInt64(WriteMaxInt64(v)))
+		assert.Equal(t, uint64(v), *ReadMinUint64(WriteMinUint64(uint64(v))))
+		assert.Equal(t, uint64(v), *ReadMaxUint64(WriteMaxUint64(uint64(v))))
+	}
+
+	assert.Equal(t, int64(math.MinInt64), firstEncoded(append([]int64{}, values...), WriteMinInt64))
+	assert.Equal(t, int64(math.MaxInt64), firstEncoded(append([]int64{}, values...), WriteMaxInt64))
+
+	// Every pair, so that encodings of different lengths are compared
+	for _, a := range values {
+		for _, b := range values {
+			pair := []int64{a, b}
+			if a < b { assert.Equal(t, b, firstEncoded(pair, WriteMaxInt64)) } else { assert.Equal(t, a, firstEncoded(pair, WriteMaxInt64)) }
+			if a < b { assert.Equal(t, a, firstEncoded(pair, WriteMinInt64)) } else { assert.Equal(t, b, firstEncoded(pair, WriteMinInt64)) }
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	register := Register{Timestamp: -7, Component: 3, Value: []byte("value")}
+	assert.Equal(t, &register, ReadFirstWriteWins(WriteFirstWriteWins(register)))
+	assert.Equal(t, &register, ReadLastWriteWins(WriteLastWriteWins(register)))
+	assert.Len(t, WriteLastWriteWins(register), 12 + 5)
+	assert.Nil(t, ReadLastWriteWins([]byte{1, 2, 3}))
+
+	empty := Register{Value: []byte{}}
+	assert.Equal(t, &empty, ReadFirstWriteWins(WriteFirstWriteWins(empty)))
+}
+
+func TestWriteWins(t *testing.T) {
+	early := Register{Timestamp: -10, Component: 9, Value: []byte("early")}
+	late := Register{Timestamp: 10, Component: 1, Value: []byte("late")}
+	tie := Register{Timestamp: 10, Component: 2, Value: []byte("tie")}
+
+	assert.Equal(t, early, FirstWriteWins(early, late))
+	assert.Equal(t, early, FirstWriteWins(late, early))
+	assert.Equal(t, late, LastWriteWins(early, late))
+	assert.Equal(t, late, LastWriteWins(late, early))
+
+	// Same timestamp; ordered by component
+	assert.Equal(t, late, FirstWriteWins(tie, late))
+	assert.Equal(t, tie, LastWriteWins(late, tie))
+
+	// Same timestamp and component; ordered by value
+	other := late
+	other.Value = []byte("another")
+	assert.Equal(t, other, FirstWriteWins(late, other))
+	assert.Equal(t, other, LastWriteWins(late, other))
+}
+
+type registerBag struct {
+	Min   *int64    `bdl:"0,min,int64"`
+	Max   uint32    `bdl:"1,max,uint32"`
+	First *Register `bdl:"2,fww"`
+	Last  Register  `bdl:"3,lww"`
+	BagState
+}
+
+func (bag *registerBag) Read(r *baggageprotocol.Reader) { ReadStruct(r, bag) }
+func (bag *registerBag) Write(w *baggageprotocol.Writer) { WriteStruct(w, bag) }
+
+func TestRegisterMerge(t *testing.T) {
+	min1, min2 := int64(-3), int64(8)
+	a := registerBag{
+		Min: &min1, Max: 4,
+		First: &Register{Timestamp: 100, Component: 1, Value: []byte("a")},
+		Last: Register{Timestamp: 100, Component: 1, Value: []byte("a")},
+	}
+	b := registerBag{
+		Min: &min2, Max: 300,
+		First: &Register{Timestamp: 200, Component: 2, Value: []byte("b")},
+		Last: Register{Timestamp: 200, Component: 2, Value: []byte("b")},
+	}
+
+	// Merging the branches keeps both values of each register; reading picks the winners
+	merged := atomlayer.Merge(writeTagged(t, &a), writeTagged(t, &b))
+	var bag registerBag
+	readTagged(t, merged, &bag)
+	assert.Equal(t, int64(-3), *bag.Min)
+	assert.Equal(t, uint32(300), bag.Max)
+	assert.Equal(t, "a", string(bag.First.Value))
+	assert.Equal(t, "b", string(bag.Last.Value))
+	assert.Empty(t, bag.GetUnprocessedAtoms())
+
+	// Merge order doesn't matter, and rewriting the bag drops the losers
+	assert.Equal(t, merged, atomlayer.Merge(writeTagged(t, &b), writeTagged(t, &a)))
+	assert.Len(t, writeTagged(t, &bag), 9)
+}
@@ -0,0 +1,276 @@
+package bdl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	mathbits "math/bits"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+)
+
+// Sketches are summaries of sets and multisets that stay small no matter how many items are added.
+//
+// Sketches, and the clocks and statistics in clocks.go and statistics.go, are mergeable types, which share a
+// convention.  Each value is the contents of one bag: Read and Write are called once the reader or writer has entered
+// its bag, as for nested bags.  Each is encoded so that merging baggage with MergeWith, which keeps the union of the
+// data atoms, merges the values.  A merged bag can hold superseded atoms, such as two versions of the same counter;
+// readers ignore them, and they are dropped the next time the bag is written.  Sketches are encoded as:
+//
+//   BloomFilter   each non-zero 64-bit word of the filter is one atom, [word index][word].  Words with the same index
+//                 are OR'd together when read
+//   HyperLogLog   each group of 8 registers containing a non-zero register is one atom, [group index][registers].
+//                 Groups with the same index take the maximum of each register when read
+//   CountMin      each non-zero counter is one atom, [counter index][count].  Counters with the same index take the
+//                 maximum when read; see CountMin for what that means for estimates
+//
+// The sketch's parameters must be the same everywhere the bag is used; atoms beyond them are ignored.  The zero value
+// of each sketch is empty, with the default parameters below.
+
+// The parameters of zero-valued sketches
+const (
+	DefaultBloomFilterBits      = 1024
+	DefaultBloomFilterHashes    = 4
+	DefaultHyperLogLogPrecision = 10
+	DefaultCountMinWidth        = 256
+	DefaultCountMinDepth        = 4
+)
+
+// A Bloom filter for set membership, with no false negatives
+type BloomFilter struct {
+	words  []uint64
+	hashes int
+}
+
+// Returns an empty Bloom filter of the given size, rounded up to a multiple of 64 bits, that sets the given number of
+// bits for each item.  Panics unless bits and hashes are positive
+func NewBloomFilter(bits, hashes int) *BloomFilter {
+	if bits <= 0 || hashes <= 0 { panic(invalidBloomFilter(bits, hashes)) }
+	return &BloomFilter{make([]uint64, (bits + 63) / 64), hashes}
+}
+
+func (filter *BloomFilter) Add(item []byte) {
+	filter.init()
+	probe(item, filter.hashes, len(filter.words) * 64, func(bit int) { filter.words[bit / 64] |= 1 << (bit % 64) })
+}
+
+// Returns false if the item was definitely never added, and true if it probably was
+func (filter *BloomFilter) Contains(item []byte) bool {
+	filter.init()
+	contains := true
+	probe(item, filter.hashes, len(filter.words) * 64, func(bit int) { contains = contains && filter.words[bit / 64] & (1 << (bit % 64)) != 0 })
+	return contains
+}
+
+// Adds the items of other, which must have the same size, to this filter
+func (filter *BloomFilter) Union(other *BloomFilter) {
+	filter.init()
+	for i := 0; i < len(filter.words) && i < len(other.words); i++ { filter.words[i] |= other.words[i] }
+}
+
+// The zero BloomFilter has the default size and number of hashes
+func (filter *BloomFilter) init() {
+	if filter.words == nil { *filter = *NewBloomFilter(DefaultBloomFilterBits, DefaultBloomFilterHashes) }
+}
+
+func (filter *BloomFilter) Read(r *baggageprotocol.Reader) {
+	filter.init()
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		index, word := readChunk(payload)
+		if len(word) == 8 && index < uint64(len(filter.words)) { filter.words[index] |= binary.BigEndian.Uint64(word) }
+	}
+}
+
+func (filter *BloomFilter) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for i, word := range filter.words {
+		if word != 0 { payloads = append(payloads, writeChunk(i, WriteUint64Fixed(word))) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// A HyperLogLog for estimating the number of distinct items
+type HyperLogLog struct {
+	registers []uint8
+	precision uint
+}
+
+// Returns an empty HyperLogLog with 2^precision registers.  The standard error of Count is 1.04/sqrt(2^precision).
+// Panics unless precision is between 4 and 16
+func NewHyperLogLog(precision int) *HyperLogLog {
+	if precision < 4 || precision > 16 { panic(invalidPrecision(precision)) }
+	return &HyperLogLog{make([]uint8, 1 << precision), uint(precision)}
+}
+
+func (hll *HyperLogLog) Add(item []byte) {
+	hll.init()
+	hash := hash64(item)
+	register := hash >> (64 - hll.precision)
+	rank := uint8(mathbits.LeadingZeros64(hash << hll.precision | 1 << (hll.precision - 1)) + 1)
+	if rank > hll.registers[register] { hll.registers[register] = rank }
+}
+
+// Returns the estimated number of distinct items added
+func (hll *HyperLogLog) Count() uint64 {
+	hll.init()
+	m := float64(len(hll.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range hll.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 { zeros++ }
+	}
+
+	var alpha float64
+	switch len(hll.registers) {
+	case 16:	alpha = 0.673
+	case 32:	alpha = 0.697
+	case 64:	alpha = 0.709
+	default:	alpha = 0.7213 / (1 + 1.079 / m)
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5 * m && zeros > 0 { estimate = m * math.Log(m / float64(zeros)) }	// Linear counting for small sets
+	return uint64(estimate + 0.5)
+}
+
+// Adds the items of other, which must have the same precision, to this HyperLogLog
+func (hll *HyperLogLog) Union(other *HyperLogLog) {
+	hll.init()
+	for i := 0; i < len(hll.registers) && i < len(other.registers); i++ {
+		if other.registers[i] > hll.registers[i] { hll.registers[i] = other.registers[i] }
+	}
+}
+
+// The zero HyperLogLog has the default precision
+func (hll *HyperLogLog) init() {
+	if hll.registers == nil { *hll = *NewHyperLogLog(DefaultHyperLogLogPrecision) }
+}
+
+func (hll *HyperLogLog) Read(r *baggageprotocol.Reader) {
+	hll.init()
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		index, registers := readChunk(payload)
+		if len(registers) != 8 || index >= uint64(len(hll.registers) / 8) { continue }
+		for i, rank := range registers {
+			if rank > hll.registers[int(index) * 8 + i] { hll.registers[int(index) * 8 + i] = rank }
+		}
+	}
+}
+
+func (hll *HyperLogLog) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for i := 0; i < len(hll.registers); i += 8 {
+		if binary.BigEndian.Uint64(hll.registers[i:i+8]) != 0 { payloads = append(payloads, writeChunk(i / 8, hll.registers[i:i+8])) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// A count-min sketch for estimating the frequencies of items.  Merged sketches keep the larger of each pair of
+// counters rather than their sum, so that the counts two branches inherit from a shared ancestor aren't counted twice.
+// The price is that items added to the same counter by concurrent branches are not summed either: after a merge, an
+// estimate can be less than the true number of occurrences, though never less than the number added along any one
+// chain of branches.
+type CountMin struct {
+	counts []uint64 // The depth rows of width counters
+	width  int
+	depth  int
+}
+
+// Returns an empty count-min sketch with depth rows of width counters.  Estimates exceed the true frequency by at most
+// e/width times the total count, with probability 1 - e^-depth.  Panics unless width and depth are positive
+func NewCountMin(width, depth int) *CountMin {
+	if width <= 0 || depth <= 0 { panic(invalidCountMin(width, depth)) }
+	return &CountMin{make([]uint64, width * depth), width, depth}
+}
+
+// Adds count occurrences of item
+func (sketch *CountMin) Add(item []byte, count uint64) {
+	sketch.init()
+	row := 0
+	probe(item, sketch.depth, sketch.width, func(column int) { sketch.counts[row * sketch.width + column] += count; row++ })
+}
+
+// Returns an estimate of the number of occurrences of item, which is never less than the true number unless
+// concurrently updated sketches have been merged
+func (sketch *CountMin) Estimate(item []byte) uint64 {
+	sketch.init()
+	estimate, row := uint64(math.MaxUint64), 0
+	probe(item, sketch.depth, sketch.width, func(column int) {
+		if count := sketch.counts[row * sketch.width + column]; count < estimate { estimate = count }
+		row++
+	})
+	return estimate
+}
+
+// Merges other, which must have the same width and depth, into this sketch, keeping the larger of each pair of counters
+func (sketch *CountMin) Union(other *CountMin) {
+	sketch.init()
+	for i := 0; i < len(sketch.counts) && i < len(other.counts); i++ {
+		if other.counts[i] > sketch.counts[i] { sketch.counts[i] = other.counts[i] }
+	}
+}
+
+// The zero CountMin has the default width and depth
+func (sketch *CountMin) init() {
+	if sketch.counts == nil { *sketch = *NewCountMin(DefaultCountMinWidth, DefaultCountMinDepth) }
+}
+
+func (sketch *CountMin) Read(r *baggageprotocol.Reader) {
+	sketch.init()
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		index, rest := readChunk(payload)
+		if index >= uint64(len(sketch.counts)) { continue }
+		if count := ReadLexVarUint64(rest); count != nil && *count > sketch.counts[index] { sketch.counts[index] = *count }
+	}
+}
+
+func (sketch *CountMin) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for i, count := range sketch.counts {
+		if count != 0 { payloads = append(payloads, writeChunk(i, WriteLexVarUint64(count))) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// Calls f with n positions in [0, size) for the item, using double hashing
+func probe(item []byte, n, size int, f func(position int)) {
+	hash := hash64(item)
+	h1, h2 := hash & math.MaxUint32, hash >> 32 | 1
+	for i := 0; i < n; i++ { f(int((h1 + uint64(i) * h2) % uint64(size))) }
+}
+
+// FNV-1a, with the MurmurHash3 finalizer so that every bit of the hash depends on every bit of the item
+func hash64(item []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(item)
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// Splits a payload into its lexicographic varint index and the remaining bytes
+func readChunk(payload []byte) (uint64, []byte) {
+	index, length := baggageprotocol.DecodeUnsignedLexVarint(payload)
+	if length == 0 { return math.MaxUint64, nil }
+	return index, payload[length:]
+}
+
+func writeChunk(index int, chunk []byte) []byte {
+	return append(WriteLexVarUint64(uint64(index)), chunk...)
+}
+
+func invalidPrecision(precision int) error {
+	return fmt.Errorf("HyperLogLog precision must be between 4 and 16, not %v", precision)
+}
+
+func invalidBloomFilter(bits, hashes int) error {
+	return fmt.Errorf("Bloom filter bits and hashes must be positive, not %v and %v", bits, hashes)
+}
+
+func invalidCountMin(width, depth int) error {
+	return fmt.Errorf("CountMin width and depth must be positive, not %v and %v", width, depth)
+}
@@ -0,0 +1,221 @@
+package bdl
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"testing"
+)
+
+type sketch interface {
+	Read(r *baggageprotocol.Reader)
+	Write(w *baggageprotocol.Writer)
+}
+
+func writeSketch(t *testing.T, s sketch) []atomlayer.Atom {
+	w := baggageprotocol.WriteBag(1)
+	w.Enter(0)
+	s.Write(w)
+	w.Exit()
+	atoms, err := w.Atoms()
+	assert.Nil(t, err)
+	return atoms
+}
+
+func readSketch(t *testing.T, atoms []atomlayer.Atom, s sketch) {
+	r := baggageprotocol.Open(atoms, 1)
+	if r.EnterIndexed(0) {
+		s.Read(r)
+		r.Exit()
+	}
+	r.Close()
+	assert.Nil(t, r.Err)
+	assert.Empty(t, r.Skipped)
+}
+
+func items(prefix string, n int) (items [][]byte) {
+	for i := 0; i < n; i++ { items = append(items, []byte(fmt.Sprintf("%v%v", prefix, i))) }
+	return
+}
+
+func TestBloomFilter(t *testing.T) {
+	assert.Panics(t, func() { NewBloomFilter(0, 4) })
+	assert.Panics(t, func() { NewBloomFilter(1000, 0) })
+	filter := NewBloomFilter(1000, 4)
+	assert.Len(t, filter.words, 16)
+	for _, item := range items("a", 50) { filter.Add(item) }
+	for _, item := range items("a", 50) { assert.True(t, filter.Contains(item)) }
+
+	falsePositives := 0
+	for _, item := range items("b", 1000) {
+		if filter.Contains(item) { falsePositives++ }
+	}
+	assert.True(t, falsePositives < 50, "%v false positives", falsePositives)
+
+	read := NewBloomFilter(1000, 4)
+	readSketch(t, writeSketch(t, filter), read)
+	assert.Equal(t, filter.words, read.words)
+}
+
+func TestHyperLogLog(t *testing.T) {
+	assert.Panics(t, func() { NewHyperLogLog(3) })
+	assert.Panics(t, func() { NewHyperLogLog(17) })
+
+	hll := NewHyperLogLog(10)
+	assert.Equal(t, uint64(0), hll.Count())
+
+	for _, item := range items("a", 10) { hll.Add(item) }
+	assert.Equal(t, uint64(10), hll.Count())
+
+	for _, item := range items("a", 20000) { hll.Add(item) }
+	for _, item := range items("a", 20000) { hll.Add(item) }
+	assert.InEpsilon(t, 20000, hll.Count(), 0.1)
+
+	read := NewHyperLogLog(10)
+	readSketch(t, writeSketch(t, hll), read)
+	assert.Equal(t, hll.registers, read.registers)
+}
+
+func TestCountMin(t *testing.T) {
+	assert.Panics(t, func() { NewCountMin(0, 4) })
+	assert.Panics(t, func() { NewCountMin(64, -1) })
+	sketch := NewCountMin(64, 4)
+	sketch.Add([]byte("x"), 10)
+	sketch.Add([]byte("x"), 5)
+	sketch.Add([]byte("y"), 1)
+	for _, item := range items("z", 20) { sketch.Add(item, 1) }
+
+	assert.True(t, sketch.Estimate([]byte("x")) >= 15)
+	assert.True(t, sketch.Estimate([]byte("y")) >= 1)
+	assert.True(t, sketch.Estimate([]byte("x")) <= 15 + 21)
+	assert.Equal(t, uint64(0), NewCountMin(64, 4).Estimate([]byte("x")))
+
+	read := NewCountMin(64, 4)
+	readSketch(t, writeSketch(t, sketch), read)
+	assert.Equal(t, sketch.counts, read.counts)
+
+	// The sketch is no larger than its counters, however many items are added
+	for _, item := range items("w", 10000) { sketch.Add(item, 1) }
+	assert.True(t, len(writeSketch(t, sketch)) <= 2 + 64 * 4)
+}
+
+// Merged counters keep the larger count, so the ancestor's occurrences are counted once, but so are only the larger
+// branch's
+func TestCountMinMergeUndercounts(t *testing.T) {
+	ancestor := NewCountMin(16, 3)
+	ancestor.Add([]byte("x"), 4)
+	base := writeSketch(t, ancestor)
+
+	a, b := NewCountMin(16, 3), NewCountMin(16, 3)
+	readSketch(t, base, a)
+	readSketch(t, base, b)
+	a.Add([]byte("x"), 1)
+	b.Add([]byte("x"), 2)
+
+	merged := NewCountMin(16, 3)
+	readSketch(t, atomlayer.Merge(writeSketch(t, a), writeSketch(t, b)), merged)
+	assert.Equal(t, uint64(6), merged.Estimate([]byte("x")))
+}
+
+// The zero value of each sketch is empty, with the default parameters
+func TestSketchZeroValues(t *testing.T) {
+	var filter BloomFilter
+	assert.False(t, filter.Contains([]byte("x")))
+	filter.Add([]byte("x"))
+	assert.True(t, filter.Contains([]byte("x")))
+	assert.Equal(t, DefaultBloomFilterHashes, filter.hashes)
+	assert.Len(t, filter.words, DefaultBloomFilterBits / 64)
+
+	var hll HyperLogLog
+	assert.Equal(t, uint64(0), hll.Count())
+	hll.Add([]byte("x"))
+	assert.Equal(t, uint64(1), hll.Count())
+	assert.Len(t, hll.registers, 1 << DefaultHyperLogLogPrecision)
+
+	var sketch CountMin
+	assert.Equal(t, uint64(0), sketch.Estimate([]byte("x")))
+	sketch.Add([]byte("x"), 3)
+	assert.Equal(t, uint64(3), sketch.Estimate([]byte("x")))
+	assert.Len(t, sketch.counts, DefaultCountMinWidth * DefaultCountMinDepth)
+
+	// Zero values read what sketches with the default parameters write
+	var readFilter BloomFilter
+	var readHLL HyperLogLog
+	var readCountMin CountMin
+	readSketch(t, writeSketch(t, &filter), &readFilter)
+	readSketch(t, writeSketch(t, &hll), &readHLL)
+	readSketch(t, writeSketch(t, &sketch), &readCountMin)
+	assert.Equal(t, filter, readFilter)
+	assert.Equal(t, hll, readHLL)
+	assert.Equal(t, sketch, readCountMin)
+}
+
+// A type that merges by atom union, and how to update and merge it in memory
+type mergeable struct {
+	name  string
+	new   func() sketch
+	add   func(s sketch, component uint32, item []byte)
+	merge func(a, b sketch) // Merges b into a
+}
+
+var mergeables = []mergeable{
+	{"BloomFilter", func() sketch { return NewBloomFilter(256, 3) },
+		func(s sketch, _ uint32, item []byte) { s.(*BloomFilter).Add(item) },
+		func(a, b sketch) { a.(*BloomFilter).Union(b.(*BloomFilter)) }},
+	{"HyperLogLog", func() sketch { return NewHyperLogLog(6) },
+		func(s sketch, _ uint32, item []byte) { s.(*HyperLogLog).Add(item) },
+		func(a, b sketch) { a.(*HyperLogLog).Union(b.(*HyperLogLog)) }},
+	{"CountMin", func() sketch { return NewCountMin(16, 3) },
+		func(s sketch, _ uint32, item []byte) { s.(*CountMin).Add(item, 1) },
+		func(a, b sketch) { a.(*CountMin).Union(b.(*CountMin)) }},
+	{"VectorClock", func() sketch { return &VectorClock{} },
+		func(s sketch, component uint32, _ []byte) { s.(*VectorClock).Increment(component) },
+		func(a, b sketch) { a.(*VectorClock).Merge(b.(*VectorClock)) }},
+	{"LamportClock", func() sketch { return &LamportClock{} },
+		func(s sketch, _ uint32, _ []byte) { s.(*LamportClock).Tick() },
+		func(a, b sketch) { a.(*LamportClock).Merge(b.(*LamportClock)) }},
+	{"Counter", func() sketch { return &Counter{} },
+		func(s sketch, component uint32, item []byte) { s.(*Counter).Increment(component, uint64(len(item))) },
+		func(a, b sketch) { a.(*Counter).Merge(b.(*Counter)) }},
+	{"Summary", func() sketch { return &Summary{} },
+		func(s sketch, component uint32, item []byte) { s.(*Summary).Add(component, int64(len(item))) },
+		func(a, b sketch) { a.(*Summary).Merge(b.(*Summary)) }},
+	{"Histogram", func() sketch { return NewHistogram(3) },
+		func(s sketch, component uint32, item []byte) { s.(*Histogram).Add(component, int64(len(item))) },
+		func(a, b sketch) { a.(*Histogram).Merge(b.(*Histogram)) }},
+}
+
+// Every mergeable type follows the convention described at the top of sketches.go
+func TestMergeProperties(t *testing.T) {
+	for _, m := range mergeables {
+		t.Run(m.name, func(t *testing.T) {
+			read := func(atoms []atomlayer.Atom) sketch { s := m.new(); readSketch(t, atoms, s); return s }
+
+			ancestor := m.new()
+			for _, item := range items("ancestor", 5) { m.add(ancestor, 1, item) }
+			base := writeSketch(t, ancestor)
+
+			// Two branches, one of which keeps the ancestor's component ID
+			a, b := read(base), read(base)
+			for _, item := range items("a", 20) { m.add(a, 1, item) }
+			for _, item := range items("b", 10) { m.add(b, 2, item) }
+			atomsA, atomsB := writeSketch(t, a), writeSketch(t, b)
+
+			// Merging the baggage is the same as merging in memory, in either order
+			mergedAtoms := atomlayer.Merge(atomsA, atomsB)
+			merged := read(mergedAtoms)
+			expected := read(atomsA)
+			m.merge(expected, b)
+			assert.Equal(t, writeSketch(t, expected), writeSketch(t, merged))
+			assert.Equal(t, writeSketch(t, merged), writeSketch(t, read(atomlayer.Merge(atomsB, atomsA))))
+
+			// Merging with an ancestor, or with a branch that is already included, changes nothing
+			assert.Equal(t, atomsA, writeSketch(t, read(atomlayer.Merge(atomsA, base))))
+			assert.Equal(t, writeSketch(t, merged), writeSketch(t, read(atomlayer.Merge(writeSketch(t, merged), atomsA))))
+
+			// Superseded atoms are dropped when the merged value is written
+			assert.True(t, len(writeSketch(t, merged)) < len(mergedAtoms))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package bdl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+)
+
+// Counters and statistics aggregated across every component that a request touches.  Each component updates only its
+// own slot, keyed by the baggage's ComponentID, and the slot's count only ever grows, so merging branches with
+// MergeWith keeps the latest slot of each component.  The aggregate is the combination of all components' slots.
+// These are mergeable types, as described in sketches.go, and each slot is one atom:
+//
+//   Counter     [component ID][count]
+//   Summary     [component ID][count][sum][min][max]
+//   Histogram   [component ID][count][count of each bucket...]
+//
+// where the numbers are lexicographic varints.  Slots of the same component with smaller counts are superseded.
+
+// A counter that can be incremented concurrently by different components.  The zero value is 0
+type Counter struct {
+	slots map[uint32]uint64
+}
+
+// Adds delta to the counter on behalf of the given component
+func (counter *Counter) Increment(component uint32, delta uint64) {
+	if counter.slots == nil { counter.slots = make(map[uint32]uint64) }
+	counter.slots[component] += delta
+}
+
+// Returns the total of all components' increments
+func (counter *Counter) Value() (value uint64) {
+	for _, count := range counter.slots { value += count }
+	return
+}
+
+// Adds the increments of other, keeping the latest slot of each component
+func (counter *Counter) Merge(other *Counter) {
+	for component, count := range other.slots { counter.witness(component, count) }
+}
+
+func (counter *Counter) witness(component uint32, count uint64) {
+	if counter.slots == nil { counter.slots = make(map[uint32]uint64) }
+	if count > counter.slots[component] { counter.slots[component] = count }
+}
+
+func (counter *Counter) Read(r *baggageprotocol.Reader) {
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		if component, count, rest, ok := readSlot(payload); ok && len(rest) == 0 { counter.witness(component, count) }
+	}
+}
+
+func (counter *Counter) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for component, count := range counter.slots {
+		if count != 0 { payloads = append(payloads, writeSlot(component, count)) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// The count, sum, minimum and maximum of values recorded by different components.  The zero value is empty
+type Summary struct {
+	slots map[uint32]summarySlot
+}
+
+type summarySlot struct {
+	count    uint64
+	sum      int64
+	min, max int64
+}
+
+// Records a value on behalf of the given component
+func (summary *Summary) Add(component uint32, value int64) {
+	if summary.slots == nil { summary.slots = make(map[uint32]summarySlot) }
+	slot, exists := summary.slots[component]
+	switch {
+	case !exists:			slot.min, slot.max = value, value
+	case value < slot.min:	slot.min = value
+	case value > slot.max:	slot.max = value
+	}
+	slot.count++
+	slot.sum += value
+	summary.slots[component] = slot
+}
+
+// Returns the number of values recorded
+func (summary *Summary) Count() (count uint64) {
+	for _, slot := range summary.slots { count += slot.count }
+	return
+}
+
+// Returns the sum of the values recorded
+func (summary *Summary) Sum() (sum int64) {
+	for _, slot := range summary.slots { sum += slot.sum }
+	return
+}
+
+// Returns the smallest value recorded, or 0 if the summary is empty
+func (summary *Summary) Min() (min int64) {
+	first := true
+	for _, slot := range summary.slots {
+		if first || slot.min < min { min, first = slot.min, false }
+	}
+	return
+}
+
+// Returns the largest value recorded, or 0 if the summary is empty
+func (summary *Summary) Max() (max int64) {
+	first := true
+	for _, slot := range summary.slots {
+		if first || slot.max > max { max, first = slot.max, false }
+	}
+	return
+}
+
+// Returns the mean of the values recorded, or NaN if the summary is empty
+func (summary *Summary) Mean() float64 {
+	return float64(summary.Sum()) / float64(summary.Count())
+}
+
+// Adds the values of other, keeping the latest slot of each component
+func (summary *Summary) Merge(other *Summary) {
+	for component, slot := range other.slots { summary.witness(component, slot) }
+}
+
+func (summary *Summary) witness(component uint32, slot summarySlot) {
+	if summary.slots == nil { summary.slots = make(map[uint32]summarySlot) }
+	if slot.count > summary.slots[component].count { summary.slots[component] = slot }
+}
+
+func (summary *Summary) Read(r *baggageprotocol.Reader) {
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		component, count, rest, ok := readSlot(payload)
+		if !ok || count == 0 { continue }
+		values, ok := readSignedLexVarints(rest)
+		if ok && len(values) == 3 { summary.witness(component, summarySlot{count, values[0], values[1], values[2]}) }
+	}
+}
+
+func (summary *Summary) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for component, slot := range summary.slots {
+		if slot.count != 0 { payloads = append(payloads, writeSlot(component, slot.count, slot.sum, slot.min, slot.max)) }
+	}
+	w.WriteSorted(payloads...)
+}
+
+// A histogram of values recorded by different components, with fixed bucket bounds.  The zero value is an empty
+// histogram with no bounds, whose only bucket holds every value
+type Histogram struct {
+	bounds []int64
+	slots  map[uint32][]uint64 // The count of each component, followed by the count of each of its buckets
+}
+
+// Returns an empty histogram whose buckets are the values up to and including each of the bounds, in increasing
+// order, followed by the values greater than the last bound.  Panics if the bounds are not increasing
+func NewHistogram(bounds ...int64) *Histogram {
+	if !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] <= bounds[j] }) { panic(unsortedBounds(bounds)) }
+	return &Histogram{append([]int64{}, bounds...), make(map[uint32][]uint64)}
+}
+
+// Records a value on behalf of the given component
+func (histogram *Histogram) Add(component uint32, value int64) {
+	if histogram.slots == nil { histogram.slots = make(map[uint32][]uint64) }
+	slot, exists := histogram.slots[component]
+	if !exists {
+		slot = make([]uint64, len(histogram.bounds) + 2)
+		histogram.slots[component] = slot
+	}
+	slot[0]++
+	slot[1 + sort.Search(len(histogram.bounds), func(i int) bool { return value <= histogram.bounds[i] })]++
+}
+
+func (histogram *Histogram) Bounds() []int64 {
+	return histogram.bounds
+}
+
+// Returns the number of values in each bucket
+func (histogram *Histogram) Counts() []uint64 {
+	counts := make([]uint64, len(histogram.bounds) + 1)
+	for _, slot := range histogram.slots {
+		for i := range counts { counts[i] += slot[i + 1] }
+	}
+	return counts
+}
+
+// Returns the number of values recorded
+func (histogram *Histogram) Count() (count uint64) {
+	for _, slot := range histogram.slots { count += slot[0] }
+	return
+}
+
+// Returns the bound of the bucket containing the given quantile, between 0 and 1, or math.MaxInt64 if it is beyond the
+// last bound.  Quantile 0 is the first non-empty bucket.  Returns 0 if the histogram is empty
+func (histogram *Histogram) Quantile(quantile float64) int64 {
+	total := histogram.Count()
+	if total == 0 { return 0 }
+	rank := max(uint64(math.Ceil(quantile * float64(total))), 1)
+	var seen uint64
+	for i, count := range histogram.Counts() {
+		seen += count
+		if count == 0 || seen < rank { continue }
+		if i < len(histogram.bounds) { return histogram.bounds[i] }
+		break
+	}
+	return math.MaxInt64
+}
+
+// Adds the values of other, which must have the same bounds, keeping the latest slot of each component
+func (histogram *Histogram) Merge(other *Histogram) {
+	for component, slot := range other.slots { histogram.witness(component, slot) }
+}
+
+func (histogram *Histogram) witness(component uint32, slot []uint64) {
+	if len(slot) != len(histogram.bounds) + 2 { return }
+	if histogram.slots == nil { histogram.slots = make(map[uint32][]uint64) }
+	if existing, exists := histogram.slots[component]; exists && slot[0] <= existing[0] { return }
+	histogram.slots[component] = append([]uint64{}, slot...)
+}
+
+func (histogram *Histogram) Read(r *baggageprotocol.Reader) {
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		component, count, rest, ok := readSlot(payload)
+		if !ok || count == 0 { continue }
+		if buckets, ok := readUnsignedLexVarints(rest); ok { histogram.witness(component, append([]uint64{count}, buckets...)) }
+	}
+}
+
+func (histogram *Histogram) Write(w *baggageprotocol.Writer) {
+	var payloads [][]byte
+	for component, slot := range histogram.slots {
+		payload := writeSlot(component, slot[0])
+		for _, count := range slot[1:] { payload = append(payload, WriteLexVarUint64(count)...) }
+		payloads = append(payloads, payload)
+	}
+	w.WriteSorted(payloads...)
+}
+
+// Splits a slot into its component ID, its count, and the remaining bytes
+func readSlot(payload []byte) (component uint32, count uint64, rest []byte, ok bool) {
+	if len(payload) <= 4 { return }
+	count, length := baggageprotocol.DecodeUnsignedLexVarint(payload[4:])
+	return binary.BigEndian.Uint32(payload), count, payload[4+length:], length > 0
+}
+
+func writeSlot(component uint32, count uint64, values ...int64) []byte {
+	payload := append(WriteUint32Fixed(component), WriteLexVarUint64(count)...)
+	for _, value := range values { payload = append(payload, WriteLexVarInt64(value)...) }
+	return payload
+}
+
+func readSignedLexVarints(payload []byte) (values []int64, ok bool) {
+	for len(payload) > 0 {
+		value, length := baggageprotocol.DecodeSignedLexVarint(payload)
+		if length == 0 { return nil, false }
+		values, payload = append(values, value), payload[length:]
+	}
+	return values, true
+}
+
+func readUnsignedLexVarints(payload []byte) (values []uint64, ok bool) {
+	for len(payload) > 0 {
+		value, length := baggageprotocol.DecodeUnsignedLexVarint(payload)
+		if length == 0 { return nil, false }
+		values, payload = append(values, value), payload[length:]
+	}
+	return values, true
+}
+
+func unsortedBounds(bounds []int64) error {
+	return fmt.Errorf("Histogram bounds must be increasing, not %v", bounds)
+}
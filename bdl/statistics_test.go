@@ -0,0 +1,98 @@
+package bdl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	var counter Counter
+	assert.Equal(t, uint64(0), counter.Value())
+	counter.Increment(1, 5)
+	counter.Increment(2, 3)
+	counter.Increment(1, 1)
+	assert.Equal(t, uint64(9), counter.Value())
+
+	var read Counter
+	readSketch(t, writeSketch(t, &counter), &read)
+	assert.Equal(t, counter.slots, read.slots)
+}
+
+func TestSummary(t *testing.T) {
+	var summary Summary
+	assert.Equal(t, uint64(0), summary.Count())
+	assert.Equal(t, int64(0), summary.Min())
+	assert.True(t, math.IsNaN(summary.Mean()))
+
+	summary.Add(1, 10)
+	summary.Add(1, -4)
+	summary.Add(1, 7)
+	summary.Add(2, 30)
+	assert.Equal(t, uint64(4), summary.Count())
+	assert.Equal(t, int64(43), summary.Sum())
+	assert.Equal(t, int64(-4), summary.Min())
+	assert.Equal(t, int64(30), summary.Max())
+	assert.Equal(t, 10.75, summary.Mean())
+
+	var read Summary
+	readSketch(t, writeSketch(t, &summary), &read)
+	assert.Equal(t, summary.slots, read.slots)
+}
+
+func TestHistogram(t *testing.T) {
+	assert.Panics(t, func() { NewHistogram(10, 5) })
+	assert.Panics(t, func() { NewHistogram(5, 5) })
+
+	histogram := NewHistogram(10, 100, 1000)
+	for _, value := range []int64{-5, 10, 11, 50, 100, 999, 5000} { histogram.Add(1, value) }
+	histogram.Add(2, 20)
+	assert.Equal(t, []int64{10, 100, 1000}, histogram.Bounds())
+	assert.Equal(t, []uint64{2, 4, 1, 1}, histogram.Counts())
+	assert.Equal(t, uint64(8), histogram.Count())
+	assert.Equal(t, int64(10), histogram.Quantile(0.25))
+	assert.Equal(t, int64(100), histogram.Quantile(0.5))
+	assert.Equal(t, int64(math.MaxInt64), histogram.Quantile(1))
+
+	read := NewHistogram(10, 100, 1000)
+	readSketch(t, writeSketch(t, histogram), read)
+	assert.Equal(t, histogram.slots, read.slots)
+
+	// Slots with different buckets are ignored
+	other := NewHistogram(10)
+	readSketch(t, writeSketch(t, histogram), other)
+	assert.Equal(t, uint64(0), other.Count())
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	histogram := NewHistogram(10, 100, 1000)
+	assert.Equal(t, int64(0), histogram.Quantile(0))
+	assert.Equal(t, int64(0), histogram.Quantile(0.5))
+
+	// Empty buckets are skipped
+	histogram.Add(1, 50)
+	histogram.Add(1, 500)
+	assert.Equal(t, int64(100), histogram.Quantile(0))
+	assert.Equal(t, int64(100), histogram.Quantile(0.5))
+	assert.Equal(t, int64(1000), histogram.Quantile(0.75))
+	assert.Equal(t, int64(1000), histogram.Quantile(1))
+}
+
+func TestHistogramZeroValue(t *testing.T) {
+	var histogram Histogram
+	assert.Equal(t, int64(0), histogram.Quantile(0.5))
+	histogram.Add(1, 5)
+	histogram.Add(2, -5)
+	assert.Equal(t, []uint64{2}, histogram.Counts())
+	assert.Equal(t, int64(math.MaxInt64), histogram.Quantile(0.5))
+
+	// Each slot is just the component's count and its one bucket
+	var read Histogram
+	readSketch(t, writeSketch(t, &histogram), &read)
+	assert.Equal(t, histogram.slots, read.slots)
+
+	var merged Histogram
+	merged.Merge(&histogram)
+	assert.Equal(t, uint64(2), merged.Count())
+}
+
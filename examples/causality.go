@@ -0,0 +1,101 @@
+package examples
+
+import (
+	"github.com/tracingplane/tracingplane-go/bdl"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// An example of the class that would be generated by BDL for a bag of logical clocks:
+//
+//   bag Causality {
+//       vectorclock Vector = 0;
+//       lamportclock Lamport = 1;
+//   }
+//
+// Storage layers can call RecordEvent when a write happens, store the returned clock alongside the write, and compare
+// the clocks of two writes to detect whether they were concurrent.
+
+// The root bag index of Causality
+const CausalityBagIndex = 7
+
+func init() {
+	tracingplane.Register(CausalityBagIndex, "causality", func() bdl.Bag { return &Causality{} })
+}
+
+type Causality struct {
+	Vector     bdl.VectorClock  // vectorclock Vector = 0;
+	Lamport    bdl.LamportClock // lamportclock Lamport = 1;
+	overflowed bool
+	unknown    []atomlayer.Atom
+}
+
+// Records an event in the baggage's component, incrementing both clocks, and returns the updated clocks
+func RecordEvent(baggage *tracingplane.BaggageContext) (*Causality, error) {
+	var causality *Causality
+	err := tracingplane.Update(baggage, func(c *Causality) {
+		c.Vector.Increment(baggage.ComponentID())
+		c.Lamport.Tick()
+		causality = c
+	})
+	return causality, err
+}
+
+// Returns true if this bag lost data because it was trimmed, or because it may have been dropped entirely
+func (causality *Causality) Overflowed() bool {
+	return causality.overflowed
+}
+
+func (causality *Causality) Read(r *baggageprotocol.Reader) {
+	// Vector
+	if r.EnterIndexed(0) {
+		causality.Vector.Read(r)
+		r.Exit()
+	}
+
+	// Lamport
+	if r.EnterIndexed(1) {
+		causality.Lamport.Read(r)
+		r.Exit()
+	}
+
+	// Overflow
+	causality.overflowed = r.Overflowed
+}
+
+func (causality *Causality) Write(w *baggageprotocol.Writer) {
+	// Vector
+	if len(causality.Vector.Components()) > 0 {
+		w.Enter(0)
+		causality.Vector.Write(w)
+		w.Exit()
+	}
+
+	// Lamport
+	if causality.Lamport.Time() > 0 {
+		w.Enter(1)
+		causality.Lamport.Write(w)
+		w.Exit()
+	}
+
+	// Overflow
+	if causality.overflowed {
+		w.MarkOverflow()
+	}
+}
+
+func (causality *Causality) SetUnprocessedAtoms(atoms []atomlayer.Atom) {
+	causality.unknown = atoms
+}
+
+func (causality *Causality) GetUnprocessedAtoms() []atomlayer.Atom {
+	return causality.unknown
+}
+
+func (causality *Causality) Clone() bdl.Bag {
+	clone := *causality
+	clone.Vector = *causality.Vector.Clone()
+	clone.unknown = causality.unknown[:len(causality.unknown):len(causality.unknown)]
+	return &clone
+}
@@ -0,0 +1,61 @@
+package examples
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+func TestCausality(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	first, err := RecordEvent(&baggage)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), first.Lamport.Time())
+
+	// Events on concurrent branches are concurrent
+	a, b := baggage.Branch(), baggage.Branch()
+	assert.NotEqual(t, a.ComponentID(), b.ComponentID())
+	eventA, err := RecordEvent(&a)
+	assert.Nil(t, err)
+	eventB, err := RecordEvent(&b)
+	assert.Nil(t, err)
+	assert.True(t, first.Vector.Before(&eventA.Vector))
+	assert.True(t, eventA.Vector.ConcurrentWith(&eventB.Vector))
+	assert.Equal(t, bdl.Identical, eventA.Lamport.Compare(&eventB.Lamport))
+
+	// Events after merging the branches happen after both
+	merged := a.MergeWith(b)
+	eventC, err := RecordEvent(&merged)
+	assert.Nil(t, err)
+	assert.True(t, eventA.Vector.Before(&eventC.Vector))
+	assert.True(t, eventB.Vector.Before(&eventC.Vector))
+	assert.Equal(t, uint64(3), eventC.Lamport.Time())
+	assert.Len(t, eventC.Vector.Components(), 3)
+
+	stored, err := tracingplane.Get[*Causality](&merged)
+	assert.Nil(t, err)
+	assert.Equal(t, bdl.Identical, stored.Vector.Compare(&eventC.Vector))
+}
+
+// An absent bag is only overflowed if trimming may have dropped it
+func TestCausalityDroppedOverflow(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	baggage.Atoms = atoms(header(0, RequestInfoBagIndex), atomlayer.TrimMarker, data(1), header(0, 8), data(1))
+	var causality Causality
+	assert.Nil(t, baggage.ReadBag(CausalityBagIndex, &causality))
+	assert.False(t, causality.Overflowed())
+
+	baggage.Atoms = atoms(header(0, RequestInfoBagIndex), data(1), atomlayer.TrimMarker)
+	assert.Nil(t, baggage.ReadBag(CausalityBagIndex, &causality))
+	assert.True(t, causality.Overflowed())
+}
+
+func TestCausalityClone(t *testing.T) {
+	var causality Causality
+	causality.Vector.Increment(1)
+	clone := causality.Clone().(*Causality)
+	clone.Vector.Increment(1)
+	assert.Equal(t, uint64(1), causality.Vector.Get(1))
+}
@@ -0,0 +1,294 @@
+package examples
+
+import (
+	"github.com/tracingplane/tracingplane-go/bdl"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// An example of the classes that would be generated by BDL for a bag containing nested bags:
+//
+//   bag RequestInfo {
+//       string RequestID = 0;
+//       Client Client = 1;
+//       Deadline Deadline = 2;
+//   }
+//
+//   bag Client {
+//       string Service = 0;
+//       string Version = 1;
+//   }
+//
+//   bag Deadline {
+//       sfixed64 UnixNanos = 0;
+//       taint Exceeded = 1;
+//   }
+//
+// Nested bags are read and written recursively, entering the child bag with Reader.EnterIndexed or Writer.Enter.  Only
+// the root bag is a bdl.Bag.  Unknown atoms at every nesting level are skipped by the same Reader, so they are all
+// preserved by the root bag, with their fully qualified paths.
+
+// The root bag index of RequestInfo
+const RequestInfoBagIndex = 6
+
+func init() {
+	tracingplane.Register(RequestInfoBagIndex, "requestinfo", func() bdl.Bag { return &RequestInfo{} })
+}
+
+type RequestInfo struct {
+	RequestID  *string   // string RequestID = 0;
+	Client     *Client   // Client Client = 1;
+	Deadline   *Deadline // Deadline Deadline = 2;
+	overflowed bool
+	unknown    []atomlayer.Atom
+}
+
+type Client struct {
+	Service *string // string Service = 0;
+	Version *string // string Version = 1;
+}
+
+type Deadline struct {
+	UnixNanos *int64 // sfixed64 UnixNanos = 0;
+	Exceeded  *bool  // taint Exceeded = 1;
+}
+
+func (requestInfo *RequestInfo) HasRequestID() bool {
+	return requestInfo.RequestID != nil
+}
+
+func (requestInfo *RequestInfo) GetRequestID() string {
+	return *requestInfo.RequestID
+}
+
+func (requestInfo *RequestInfo) SetRequestID(requestID string) {
+	requestInfo.RequestID = &requestID
+}
+
+func (requestInfo *RequestInfo) HasClient() bool {
+	return requestInfo.Client != nil
+}
+
+// Returns the Client bag, creating it if it does not exist
+func (requestInfo *RequestInfo) GetClient() *Client {
+	if requestInfo.Client == nil { requestInfo.Client = &Client{} }
+	return requestInfo.Client
+}
+
+func (requestInfo *RequestInfo) HasDeadline() bool {
+	return requestInfo.Deadline != nil
+}
+
+// Returns the Deadline bag, creating it if it does not exist
+func (requestInfo *RequestInfo) GetDeadline() *Deadline {
+	if requestInfo.Deadline == nil { requestInfo.Deadline = &Deadline{} }
+	return requestInfo.Deadline
+}
+
+// Returns true if this bag lost data because it was trimmed, or because it may have been dropped entirely
+func (requestInfo *RequestInfo) Overflowed() bool {
+	return requestInfo.overflowed
+}
+
+func (client *Client) HasService() bool {
+	return client.Service != nil
+}
+
+func (client *Client) GetService() string {
+	return *client.Service
+}
+
+func (client *Client) SetService(service string) {
+	client.Service = &service
+}
+
+func (client *Client) HasVersion() bool {
+	return client.Version != nil
+}
+
+func (client *Client) GetVersion() string {
+	return *client.Version
+}
+
+func (client *Client) SetVersion(version string) {
+	client.Version = &version
+}
+
+func (deadline *Deadline) HasUnixNanos() bool {
+	return deadline.UnixNanos != nil
+}
+
+func (deadline *Deadline) GetUnixNanos() int64 {
+	return *deadline.UnixNanos
+}
+
+func (deadline *Deadline) SetUnixNanos(unixNanos int64) {
+	deadline.UnixNanos = &unixNanos
+}
+
+func (deadline *Deadline) HasExceeded() bool {
+	return deadline.Exceeded != nil
+}
+
+func (deadline *Deadline) GetExceeded() bool {
+	return *deadline.Exceeded
+}
+
+func (deadline *Deadline) SetExceeded(exceeded bool) {
+	deadline.Exceeded = &exceeded
+}
+
+func (requestInfo *RequestInfo) Read(r *baggageprotocol.Reader) {
+	// RequestID
+	if r.EnterIndexed(0) {
+		requestInfo.RequestID = readString(r.Next())
+		r.Exit()
+	}
+
+	// Client
+	if r.EnterIndexed(1) {
+		requestInfo.Client = &Client{}
+		requestInfo.Client.read(r)
+		r.Exit()
+	}
+
+	// Deadline
+	if r.EnterIndexed(2) {
+		requestInfo.Deadline = &Deadline{}
+		requestInfo.Deadline.read(r)
+		r.Exit()
+	}
+
+	// Overflow
+	requestInfo.overflowed = r.Overflowed
+}
+
+func (requestInfo *RequestInfo) Write(w *baggageprotocol.Writer) {
+	// RequestID
+	if requestInfo.RequestID != nil {
+		w.Enter(0)
+		w.Write([]byte(*requestInfo.RequestID))
+		w.Exit()
+	}
+
+	// Client
+	if requestInfo.Client != nil {
+		w.Enter(1)
+		requestInfo.Client.write(w)
+		w.Exit()
+	}
+
+	// Deadline
+	if requestInfo.Deadline != nil {
+		w.Enter(2)
+		requestInfo.Deadline.write(w)
+		w.Exit()
+	}
+
+	// Overflow
+	if requestInfo.overflowed {
+		w.MarkOverflow()
+	}
+}
+
+// Reads the Client bag, which the reader has already entered
+func (client *Client) read(r *baggageprotocol.Reader) {
+	// Service
+	if r.EnterIndexed(0) {
+		client.Service = readString(r.Next())
+		r.Exit()
+	}
+
+	// Version
+	if r.EnterIndexed(1) {
+		client.Version = readString(r.Next())
+		r.Exit()
+	}
+}
+
+// Writes the Client bag, which the writer has already entered
+func (client *Client) write(w *baggageprotocol.Writer) {
+	// Service
+	if client.Service != nil {
+		w.Enter(0)
+		w.Write([]byte(*client.Service))
+		w.Exit()
+	}
+
+	// Version
+	if client.Version != nil {
+		w.Enter(1)
+		w.Write([]byte(*client.Version))
+		w.Exit()
+	}
+}
+
+// Reads the Deadline bag, which the reader has already entered
+func (deadline *Deadline) read(r *baggageprotocol.Reader) {
+	// UnixNanos
+	if r.EnterIndexed(0) {
+		deadline.UnixNanos = bdl.ReadInt64Fixed(r.Next())
+		r.Exit()
+	}
+
+	// Exceeded
+	if r.EnterIndexed(1) {
+		deadline.Exceeded = bdl.ReadTaint(r.Next())
+		r.Exit()
+	}
+}
+
+// Writes the Deadline bag, which the writer has already entered
+func (deadline *Deadline) write(w *baggageprotocol.Writer) {
+	// UnixNanos
+	if deadline.UnixNanos != nil {
+		w.Enter(0)
+		w.Write(bdl.WriteInt64Fixed(*deadline.UnixNanos))
+		w.Exit()
+	}
+
+	// Exceeded
+	if deadline.Exceeded != nil {
+		w.Enter(1)
+		w.Write(bdl.WriteTaint(*deadline.Exceeded))
+		w.Exit()
+	}
+}
+
+func (requestInfo *RequestInfo) SetUnprocessedAtoms(atoms []atomlayer.Atom) {
+	requestInfo.unknown = atoms
+}
+
+func (requestInfo *RequestInfo) GetUnprocessedAtoms() []atomlayer.Atom {
+	return requestInfo.unknown
+}
+
+func (requestInfo *RequestInfo) Clone() bdl.Bag {
+	clone := *requestInfo
+	if requestInfo.RequestID != nil { requestID := *requestInfo.RequestID; clone.RequestID = &requestID }
+	if requestInfo.Client != nil { clone.Client = requestInfo.Client.clone() }
+	if requestInfo.Deadline != nil { clone.Deadline = requestInfo.Deadline.clone() }
+	clone.unknown = requestInfo.unknown[:len(requestInfo.unknown):len(requestInfo.unknown)]
+	return &clone
+}
+
+func (client *Client) clone() *Client {
+	clone := *client
+	if client.Service != nil { service := *client.Service; clone.Service = &service }
+	if client.Version != nil { version := *client.Version; clone.Version = &version }
+	return &clone
+}
+
+func (deadline *Deadline) clone() *Deadline {
+	clone := *deadline
+	if deadline.UnixNanos != nil { unixNanos := *deadline.UnixNanos; clone.UnixNanos = &unixNanos }
+	if deadline.Exceeded != nil { exceeded := *deadline.Exceeded; clone.Exceeded = &exceeded }
+	return &clone
+}
+
+func readString(payload []byte) *string {
+	if payload == nil { return nil }
+	value := string(payload)
+	return &value
+}
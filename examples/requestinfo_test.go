@@ -0,0 +1,171 @@
+package examples
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+func TestRequestInfo(t *testing.T) {
+	var info RequestInfo
+	info.SetRequestID("abc")
+	info.GetClient().SetService("frontend")
+	info.GetClient().SetVersion("1.2")
+	info.GetDeadline().SetUnixNanos(-5)
+	info.GetDeadline().SetExceeded(true)
+
+	var baggage tracingplane.BaggageContext
+	assert.Nil(t, baggage.Set(RequestInfoBagIndex, &info))
+	assert.Equal(t, atoms(
+		header(0, RequestInfoBagIndex),
+			header(1, 0),
+				data('a', 'b', 'c'),
+			header(1, 1),
+				header(2, 0),
+					data([]byte("frontend")...),
+				header(2, 1),
+					data('1', '.', '2'),
+			header(1, 2),
+				header(2, 0),
+					data(bdl.WriteInt64Fixed(-5)...),
+				header(2, 1),
+					data(1),
+	), baggage.Atoms)
+
+	var read RequestInfo
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &read))
+	assert.Equal(t, "abc", read.GetRequestID())
+	assert.True(t, read.HasClient())
+	assert.Equal(t, "frontend", read.Client.GetService())
+	assert.Equal(t, "1.2", read.Client.GetVersion())
+	assert.True(t, read.HasDeadline())
+	assert.Equal(t, int64(-5), read.Deadline.GetUnixNanos())
+	assert.True(t, read.Deadline.GetExceeded())
+	assert.False(t, read.Overflowed())
+	assert.Empty(t, read.unknown)
+}
+
+func TestRequestInfoAbsentNestedBags(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	baggage.Atoms = atoms(
+		header(0, RequestInfoBagIndex),
+			header(1, 1),
+				header(2, 1),
+					data('2'),
+	)
+
+	var info RequestInfo
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &info))
+	assert.False(t, info.HasRequestID())
+	assert.False(t, info.HasDeadline())
+	assert.True(t, info.HasClient())
+	assert.False(t, info.Client.HasService())
+	assert.Equal(t, "2", info.Client.GetVersion())
+}
+
+func TestRequestInfoUnknownAtoms(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	baggage.Atoms = atoms(
+		header(0, RequestInfoBagIndex),
+			header(1, 0),
+				data('a'),
+			header(1, 1),
+				header(2, 0),
+					data('s'),
+				header(2, 7),		// Unknown field of Client
+					data(7),
+			header(1, 2),
+				header(2, 1),
+					data(1),
+				keyed(2, "x"),		// Unknown keyed child of Deadline
+					data(8),
+			header(1, 9),			// Unknown field of RequestInfo
+				data(9),
+	)
+
+	var info RequestInfo
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &info))
+	assert.Len(t, info.GetUnprocessedAtoms(), 8)	// Unknown atoms, with the headers of their enclosing bags
+
+	// Modify known fields at each level; unknown atoms stay where they were
+	info.SetRequestID("b")
+	info.Client.SetVersion("v")
+	info.Deadline.SetUnixNanos(1)
+
+	var modified tracingplane.BaggageContext
+	assert.Nil(t, modified.Set(RequestInfoBagIndex, &info))
+	assert.Equal(t, atoms(
+		header(0, RequestInfoBagIndex),
+			header(1, 0),
+				data('b'),
+			header(1, 1),
+				header(2, 0),
+					data('s'),
+				header(2, 1),
+					data('v'),
+				header(2, 7),
+					data(7),
+			header(1, 2),
+				header(2, 0),
+					data(bdl.WriteInt64Fixed(1)...),
+				header(2, 1),
+					data(1),
+				keyed(2, "x"),
+					data(8),
+			header(1, 9),
+				data(9),
+	), modified.Atoms)
+}
+
+func TestRequestInfoNestedOverflow(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	baggage.Atoms = atoms(
+		header(0, RequestInfoBagIndex),
+			header(1, 1),
+				header(2, 0),
+					data('s'),
+				atomlayer.Atom{},
+	)
+
+	var info RequestInfo
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &info))
+	assert.Equal(t, "s", info.Client.GetService())
+	assert.True(t, info.Overflowed())
+}
+
+// An absent bag is only overflowed if trimming may have dropped it
+func TestRequestInfoDroppedOverflow(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	baggage.Atoms = atoms(header(0, 2), atomlayer.TrimMarker, data(1), header(0, 7), data(1))
+	var info RequestInfo
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &info))
+	assert.False(t, info.Overflowed())
+
+	baggage.Atoms = atoms(header(0, 2), data(1), atomlayer.TrimMarker)
+	assert.Nil(t, baggage.ReadBag(RequestInfoBagIndex, &info))
+	assert.True(t, info.Overflowed())
+}
+
+func TestRequestInfoClone(t *testing.T) {
+	var info RequestInfo
+	info.SetRequestID("a")
+	info.GetClient().SetService("s")
+	info.GetDeadline().SetExceeded(false)
+
+	clone := info.Clone().(*RequestInfo)
+	clone.SetRequestID("b")
+	clone.Client.SetService("t")
+	clone.Deadline.SetExceeded(true)
+	assert.Equal(t, "a", info.GetRequestID())
+	assert.Equal(t, "s", info.Client.GetService())
+	assert.False(t, info.Deadline.GetExceeded())
+}
+
+func TestRequestInfoRegistered(t *testing.T) {
+	bagType, exists := tracingplane.LookupBagName("requestinfo")
+	assert.True(t, exists)
+	assert.Equal(t, uint64(RequestInfoBagIndex), bagType.Index)
+	assert.IsType(t, &RequestInfo{}, bagType.New())
+}
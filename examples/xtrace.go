@@ -1,13 +1,23 @@
 package examples
 
 import (
+	"encoding/json"
+	"sort"
 	"github.com/tracingplane/tracingplane-go/bdl"
 	"github.com/tracingplane/tracingplane-go/baggageprotocol"
 	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
 )
 
 // An example of a class that would be generated by BDL for XTrace
 
+// The root bag index of XTraceMetadata
+const XTraceBagIndex = 5
+
+func init() {
+	tracingplane.Register(XTraceBagIndex, "xtrace", func() bdl.Bag { return &XTraceMetadata{} })
+}
+
 type XTraceMetadata struct {
 	taskID         *int64               // fixed64 taskID = 0
 	parentEventIDs map[int64](struct{}) // set<fixed64> parentEventIDs = 1
@@ -53,6 +63,7 @@ func (xTraceMetadata *XTraceMetadata) GetParentEventIDs() (parentEventIDs []int6
 	return
 }
 
+// Returns true if this bag lost data because it was trimmed, or because it may have been dropped entirely
 func (xTraceMetadata *XTraceMetadata) Overflowed() bool {
 	return xTraceMetadata.overflowed
 }
@@ -107,10 +118,43 @@ func (xTraceMetadata *XTraceMetadata) Write(w *baggageprotocol.Writer) {
 	}
 }
 
+// The JSON representation of XTraceMetadata, whose fields are unexported
+type xTraceMetadataJSON struct {
+	TaskID         *int64  `json:",omitempty"`
+	ParentEventIDs []int64 `json:",omitempty"` // In increasing order
+}
+
+// Implements json.Marshaler, so that the tracing plane's JSON encoding of baggage can show XTraceMetadata as a typed
+// value.  Overflow and unknown atoms are not included.
+func (xTraceMetadata *XTraceMetadata) MarshalJSON() ([]byte, error) {
+	parentEventIDs := xTraceMetadata.GetParentEventIDs()
+	sort.Slice(parentEventIDs, func(i, j int) bool { return parentEventIDs[i] < parentEventIDs[j] })
+	return json.Marshal(xTraceMetadataJSON{xTraceMetadata.taskID, parentEventIDs})
+}
+
+func (xTraceMetadata *XTraceMetadata) UnmarshalJSON(data []byte) error {
+	var decoded xTraceMetadataJSON
+	if err := json.Unmarshal(data, &decoded); err != nil { return err }
+	xTraceMetadata.taskID, xTraceMetadata.parentEventIDs = decoded.TaskID, nil
+	xTraceMetadata.AddParentEventID(decoded.ParentEventIDs...)
+	return nil
+}
+
 func (xTraceMetadata *XTraceMetadata) SetUnprocessedAtoms(atoms []atomlayer.Atom) {
 	xTraceMetadata.unknown = atoms
 }
 
 func (xTraceMetadata *XTraceMetadata) GetUnprocessedAtoms() []atomlayer.Atom {
 	return xTraceMetadata.unknown
-}
\ No newline at end of file
+}
+
+func (xTraceMetadata *XTraceMetadata) Clone() bdl.Bag {
+	clone := *xTraceMetadata
+	if xTraceMetadata.taskID != nil { taskID := *xTraceMetadata.taskID; clone.taskID = &taskID }
+	if xTraceMetadata.parentEventIDs != nil {
+		clone.parentEventIDs = make(map[int64](struct{}), len(xTraceMetadata.parentEventIDs))
+		for id := range xTraceMetadata.parentEventIDs { clone.parentEventIDs[id] = struct{}{} }
+	}
+	clone.unknown = xTraceMetadata.unknown[:len(xTraceMetadata.unknown):len(xTraceMetadata.unknown)]
+	return &clone
+}
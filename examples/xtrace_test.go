@@ -6,6 +6,7 @@ import (
 	"github.com/tracingplane/tracingplane-go/tracingplane"
 	"github.com/tracingplane/tracingplane-go/baggageprotocol"
 	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/bdl"
 )
 
 
@@ -222,4 +223,76 @@ func TestXTraceUnprocessed(t *testing.T) {
 			data(100),
 	)
 	assert.Equal(t, expect, baggage.Atoms)
-}
\ No newline at end of file
+}
+
+// The XTrace bag, encoded by reflection instead of by hand
+type taggedXTrace struct {
+	TaskID         *int64             `bdl:"0,sfixed64"`
+	ParentEventIDs map[int64]struct{} `bdl:"1,set,sfixed64"`
+	bdl.BagState
+}
+
+func (x *taggedXTrace) Read(r *baggageprotocol.Reader) { bdl.ReadStruct(r, x) }
+func (x *taggedXTrace) Write(w *baggageprotocol.Writer) { bdl.WriteStruct(w, x) }
+func (x *taggedXTrace) Clone() bdl.Bag { return bdl.CloneStruct(x).(*taggedXTrace) }
+
+func TestXTraceTaggedStruct(t *testing.T) {
+	var xtrace XTraceMetadata
+	xtrace.SetTaskID(-12)
+	xtrace.AddParentEventID(7, -3, 1<<40)
+	var expected tracingplane.BaggageContext
+	assert.Nil(t, expected.Set(5, &xtrace))
+
+	var tagged taggedXTrace
+	assert.Nil(t, expected.ReadBag(5, &tagged))
+	assert.Equal(t, int64(-12), *tagged.TaskID)
+	assert.Equal(t, map[int64]struct{}{7: {}, -3: {}, 1<<40: {}}, tagged.ParentEventIDs)
+
+	var baggage tracingplane.BaggageContext
+	assert.Nil(t, baggage.Set(5, &tagged))
+	assert.Equal(t, expected.Atoms, baggage.Atoms)
+
+	// Defining Clone with CloneStruct lets BaggageContext cache the bag, without sharing it between reads
+	baggage.EnableCache()
+	var first, second taggedXTrace
+	assert.Nil(t, baggage.ReadBag(5, &first))
+	first.ParentEventIDs[8] = struct{}{}
+	assert.Nil(t, baggage.ReadBag(5, &second))
+	assert.Equal(t, tagged.ParentEventIDs, second.ParentEventIDs)
+
+	// Unknown atoms and overflow are preserved in the same way
+	trimmed := append(atoms(expected.Atoms[0], expected.Atoms[1], expected.Atoms[2], header(1, 9), data(1)), atomlayer.TrimMarker)
+	expected.Atoms, baggage.Atoms = trimmed, trimmed
+	xtrace, tagged = XTraceMetadata{}, taggedXTrace{}
+	assert.Nil(t, expected.ReadBag(5, &xtrace))
+	assert.Nil(t, baggage.ReadBag(5, &tagged))
+	assert.Equal(t, xtrace.Overflowed(), tagged.Overflowed())
+	assert.Equal(t, xtrace.GetUnprocessedAtoms(), tagged.GetUnprocessedAtoms())
+	assert.Nil(t, expected.Set(5, &xtrace))
+	assert.Nil(t, baggage.Set(5, &tagged))
+	assert.Equal(t, expected.Atoms, baggage.Atoms)
+}
+
+// The registered example bags are encoded as typed values in JSON
+func TestRegisteredBagsJSON(t *testing.T) {
+	var baggage tracingplane.BaggageContext
+	xmd := &XTraceMetadata{}
+	xmd.SetTaskID(7)
+	xmd.AddParentEventID(3, 1)
+	assert.Nil(t, baggage.Set(XTraceBagIndex, xmd))
+	zmd := &ZipkinMetadata{}
+	zmd.SetTraceID(55)
+	zmd.SetSpanID(70)
+	assert.Nil(t, baggage.Set(ZipkinBagIndex, zmd))
+
+	encoded, err := baggage.MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"bags": [
+		{"index": 2, "value": {"TraceID": 55, "SpanID": 70, "ParentSpanID": null, "Sampled": null, "Tags": null}},
+		{"index": 5, "value": {"TaskID": 7, "ParentEventIDs": [1, 3]}}
+	]}`, string(encoded))
+
+	var decoded tracingplane.BaggageContext
+	assert.Nil(t, decoded.UnmarshalJSON(encoded))
+	assert.Equal(t, baggage.Atoms, decoded.Atoms)
+}
@@ -4,11 +4,19 @@ import (
 	"github.com/tracingplane/tracingplane-go/bdl"
 	"github.com/tracingplane/tracingplane-go/baggageprotocol"
 	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
 	"sort"
 )
 
 // An example of a class that would be generated by BDL for Zipkin
 
+// The root bag index of ZipkinMetadata
+const ZipkinBagIndex = 2
+
+func init() {
+	tracingplane.Register(ZipkinBagIndex, "zipkin", func() bdl.Bag { return &ZipkinMetadata{} })
+}
+
 type ZipkinMetadata struct {
 	TraceID      *int64              // sfixed64 TraceID = 0;
 	SpanID       *int64              // sfixed64 SpanID = 1;
@@ -67,6 +75,7 @@ func (zipkinMetadata *ZipkinMetadata) SetSampled(sampled bool)  {
 	zipkinMetadata.Sampled = &sampled
 }
 
+// Returns true if this bag lost data because it was trimmed, or because it may have been dropped entirely
 func (zipkinMetadata *ZipkinMetadata) Overflowed() bool {
 	return zipkinMetadata.overflowed
 }
@@ -177,4 +186,18 @@ func (zipkinMetadata *ZipkinMetadata) SetUnprocessedAtoms(atoms []atomlayer.Atom
 
 func (zipkinMetadata *ZipkinMetadata) GetUnprocessedAtoms() []atomlayer.Atom {
 	return zipkinMetadata.unknown
-}
\ No newline at end of file
+}
+
+func (zipkinMetadata *ZipkinMetadata) Clone() bdl.Bag {
+	clone := *zipkinMetadata
+	if zipkinMetadata.TraceID != nil { traceID := *zipkinMetadata.TraceID; clone.TraceID = &traceID }
+	if zipkinMetadata.SpanID != nil { spanID := *zipkinMetadata.SpanID; clone.SpanID = &spanID }
+	if zipkinMetadata.ParentSpanID != nil { parentSpanID := *zipkinMetadata.ParentSpanID; clone.ParentSpanID = &parentSpanID }
+	if zipkinMetadata.Sampled != nil { sampled := *zipkinMetadata.Sampled; clone.Sampled = &sampled }
+	if zipkinMetadata.Tags != nil {
+		clone.Tags = make(map[string](string), len(zipkinMetadata.Tags))
+		for key, value := range zipkinMetadata.Tags { clone.Tags[key] = value }
+	}
+	clone.unknown = zipkinMetadata.unknown[:len(zipkinMetadata.unknown):len(zipkinMetadata.unknown)]
+	return &clone
+}
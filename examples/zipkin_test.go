@@ -36,4 +36,26 @@ func TestZipkin(t *testing.T) {
 	baggage2.Set(2, &zmd)
 
 	assert.Equal(t, baggage.Atoms, baggage2.Atoms)
-}
\ No newline at end of file
+}
+
+func TestZipkinRegistered(t *testing.T) {
+	bagType, exists := tracingplane.LookupBag(ZipkinBagIndex)
+	assert.True(t, exists)
+	assert.Equal(t, "zipkin", bagType.Name)
+	assert.IsType(t, &ZipkinMetadata{}, bagType.New())
+
+	bagType, exists = tracingplane.LookupBagName("xtrace")
+	assert.True(t, exists)
+	assert.Equal(t, uint64(XTraceBagIndex), bagType.Index)
+}
+
+func TestZipkinClone(t *testing.T) {
+	zmd := ZipkinMetadata{Tags: map[string]string{"a": "b"}}
+	zmd.SetTraceID(5)
+
+	clone := zmd.Clone().(*ZipkinMetadata)
+	clone.SetTraceID(6)
+	clone.Tags["a"] = "c"
+	assert.Equal(t, int64(5), zmd.GetTraceID())
+	assert.Equal(t, "b", zmd.Tags["a"])
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+)
+
+// Prints the atoms that differ between two baggages, one atom per line in the text format of
+// baggageprotocol.FormatText.  Each hunk begins with a line
+//
+//   @@ -<from offset>,<removed> +<to offset>,<added> @@ <path of the enclosing bag>
+//
+// followed by the removed atoms prefixed with - and the added atoms prefixed with +.  Prints nothing if the baggages
+// have the same atoms.
+func diff(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var input inputFlags
+	input.register(flags)
+	if err := flags.Parse(args); err != nil { return err }
+	if flags.NArg() != 2 { return fmt.Errorf("Expected two baggage arguments but got %v", flags.NArg()) }
+
+	baggages, err := input.readAll(flags.Args(), stdin)
+	if err != nil { return err }
+	from, to := baggages[0].Atoms, baggages[1].Atoms
+
+	fromLines, toLines := textLines(from), textLines(to)
+	shift := 0
+	for _, hunk := range atomlayer.Diff(from, to).Hunks {
+		toOffset := hunk.Offset + shift
+		fmt.Fprintf(stdout, "@@ -%v,%v +%v,%v @@%v\n", hunk.Offset, hunk.Removed, toOffset, len(hunk.Added), enclosingBag(fromLines, from, hunk.Offset))
+		for _, line := range fromLines[hunk.Offset:hunk.Offset+hunk.Removed] { fmt.Fprintf(stdout, "-%v\n", line) }
+		for _, line := range toLines[toOffset:toOffset+len(hunk.Added)] { fmt.Fprintf(stdout, "+%v\n", line) }
+		shift += len(hunk.Added) - hunk.Removed
+	}
+	return nil
+}
+
+// Formats atoms as text, returning one line per atom
+func textLines(atoms []atomlayer.Atom) []string {
+	return strings.Split(strings.TrimSuffix(baggageprotocol.FormatText(atoms), "\n"), "\n")[:len(atoms)]
+}
+
+// Returns the path of the bag containing the atom at offset, preceded by a space, or the empty string if it is not
+// within any bag
+func enclosingBag(lines []string, atoms []atomlayer.Atom, offset int) string {
+	for i := offset-1; i >= 0; i-- {
+		if baggageprotocol.IsHeader(atoms[i]) && strings.HasPrefix(strings.TrimSpace(lines[i]), "bag") {
+			return " " + strings.TrimSpace(lines[i])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"bytes"
+)
+
+func TestDiff(t *testing.T) {
+	var out bytes.Buffer
+	err := diff([]string{"0x02f802020001020002", "0x02f802020001020003"}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "@@ -2,1 +2,1 @@ bag[2]\n-  0x02\n+  0x03\n", out.String())
+
+	out.Reset()
+	err = diff([]string{"0x02f80202000100", "0x02f802020001"}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "@@ -2,1 +2,0 @@ bag[2]\n-  <trim>\n", out.String())
+
+	out.Reset()
+	err = diff([]string{"0x02f802020001", "0x02f802020001"}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "", out.String())
+
+	assert.NotNil(t, diff([]string{"0x02f802020001"}, nil, &out))
+}
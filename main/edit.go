@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// A flag that can be repeated, collecting each value
+type stringList []string
+
+func (list *stringList) String() string { return strings.Join(*list, ", ") }
+func (list *stringList) Set(value string) error { *list = append(*list, value); return nil }
+
+// Builds baggage from a description in the text format of baggageprotocol.FormatText, or in JSON if the description
+// begins with {.  The description is read from the argument, or from stdin if there is no argument.
+func build(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	var output outputFlags
+	output.register(flags)
+	if err := flags.Parse(args); err != nil { return err }
+
+	var description string
+	switch flags.NArg() {
+	case 0:
+		bytes, err := io.ReadAll(stdin)
+		if err != nil { return err }
+		description = string(bytes)
+	case 1:
+		description = flags.Arg(0)
+	default:
+		return fmt.Errorf("Expected at most one description argument but got %v", flags.NArg())
+	}
+
+	var baggage tracingplane.BaggageContext
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(description), "{") {
+		baggage, err = tracingplane.UnmarshalJSONWithSchema([]byte(description), tracingplane.DefaultRegistry)
+	} else {
+		baggage.Atoms, err = baggageprotocol.ParseText(description)
+	}
+	if err != nil { return err }
+	return output.write(stdout, baggage)
+}
+
+// Replaces the contents of the bag at a path with the provided data atoms, creating the bag if it does not exist
+func set(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	var input inputFlags
+	var output outputFlags
+	input.register(flags)
+	output.register(flags)
+	pathText := flags.String("path", "", "Path of the bag to set, eg. bag[2].[0]")
+	var values stringList
+	flags.Var(&values, "data", "A data atom, as 0x-prefixed hex or a quoted string.  May be repeated.")
+	if err := flags.Parse(args); err != nil { return err }
+
+	path, err := baggageprotocol.ParsePath(*pathText)
+	if err != nil { return fmt.Errorf("Invalid -path %q: %v", *pathText, err) }
+	bag := path
+	for _, value := range values {
+		atom, err := parseDataAtom(value)
+		if err != nil { return fmt.Errorf("Invalid -data %q: %v", value, err) }
+		bag = append(bag, atom)
+	}
+
+	baggage, err := input.read(flags.Args(), stdin)
+	if err != nil { return err }
+	baggage.Atoms = atomlayer.Merge(baggageprotocol.DropPath(baggage.Atoms, path, baggageprotocol.DropMarker), bag)
+	return output.write(stdout, baggage)
+}
+
+// Parses a single data atom written in the text format of baggageprotocol.FormatText
+func parseDataAtom(value string) (atomlayer.Atom, error) {
+	atoms, err := baggageprotocol.ParseText(value)
+	if err != nil { return nil, err }
+	if len(atoms) != 1 || !baggageprotocol.IsData(atoms[0]) { return nil, fmt.Errorf("Expected a single data atom") }
+	return atoms[0], nil
+}
+
+var markerBehaviors = map[string]baggageprotocol.OverflowMarkerBehavior{
+	"retain": baggageprotocol.RetainMarkerPosition,
+	"push":   baggageprotocol.PushMarkerDown,
+	"drop":   baggageprotocol.DropMarker,
+}
+
+// Drops the bag at a path, including all of its child bags
+func drop(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("drop", flag.ContinueOnError)
+	var input inputFlags
+	var output outputFlags
+	input.register(flags)
+	output.register(flags)
+	pathText := flags.String("path", "", "Path of the bag to drop, eg. bag[2].[0]")
+	markers := flags.String("markers", "retain", "What to do with trim markers in the dropped bag: retain, push or drop")
+	if err := flags.Parse(args); err != nil { return err }
+
+	path, err := baggageprotocol.ParsePath(*pathText)
+	if err != nil { return fmt.Errorf("Invalid -path %q: %v", *pathText, err) }
+	behavior, known := markerBehaviors[*markers]
+	if !known { return fmt.Errorf("Unknown -markers %q", *markers) }
+
+	baggage, err := input.read(flags.Args(), stdin)
+	if err != nil { return err }
+	baggage.Atoms = baggageprotocol.DropPath(baggage.Atoms, path, behavior)
+	return output.write(stdout, baggage)
+}
+
+// Trims baggage to fit into a number of serialized bytes
+func trim(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("trim", flag.ContinueOnError)
+	var input inputFlags
+	var output outputFlags
+	input.register(flags)
+	output.register(flags)
+	size := flags.Int("size", -1, "Maximum serialized size of the baggage in bytes")
+	if err := flags.Parse(args); err != nil { return err }
+	if *size < 0 { return fmt.Errorf("Missing -size") }
+
+	baggage, err := input.read(flags.Args(), stdin)
+	if err != nil { return err }
+	return output.write(stdout, tracingplane.Trim(baggage, *size))
+}
+
+// Merges several baggages, read from the arguments or from each line of stdin
+func merge(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	var input inputFlags
+	var output outputFlags
+	input.register(flags)
+	output.register(flags)
+	if err := flags.Parse(args); err != nil { return err }
+
+	baggages, err := input.readAll(flags.Args(), stdin)
+	if err != nil { return err }
+	if len(baggages) == 0 { return fmt.Errorf("Expected baggage to merge") }
+	return output.write(stdout, baggages[0].MergeWith(baggages[1:]...))
+}
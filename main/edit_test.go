@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"bytes"
+	"encoding/base64"
+	"strings"
+)
+
+func TestBuild(t *testing.T) {
+	var out bytes.Buffer
+	err := build([]string{"-output", "text"}, strings.NewReader("bag[2]\n  bag[2].[0]\n    0x0000000000000037\nbag[5]\n  0x01\n  <trim>\n"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "bag[2]\n  bag[2].[0]\n    0x0000000000000037\nbag[5]\n  0x01\n  <trim>\n", out.String())
+
+	out.Reset()
+	err = build(nil, strings.NewReader("bag[2]\n  bag[2].[0]\n    0x0000000000000037\nbag[5]\n  0x01\n  <trim>\n"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(testInput()) + "\n", out.String())
+
+	out.Reset()
+	err = build([]string{"-output", "hex", `{"bags": [{"index": 7, "data": ["AQ=="]}]}`}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x02f807020001\n", out.String())
+
+	assert.NotNil(t, build([]string{"bag[2"}, nil, &out))
+	assert.NotNil(t, build([]string{"-output", "yaml", "bag[2]"}, nil, &out))
+}
+
+func TestSet(t *testing.T) {
+	var out bytes.Buffer
+	input := base64.StdEncoding.EncodeToString(testInput())
+	err := set([]string{"-output", "text", "-path", "bag[2].[0]", "-data", `"hello"`, "-data", "0x02", input}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "bag[2]\n  bag[2].[0]\n    \"hello\"\n    0x02\nbag[5]\n  0x01\n  <trim>\n", out.String())
+
+	out.Reset()
+	err = set([]string{"-output", "text", "-path", "bag[9]", "-data", "0x03", input}, nil, &out)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(out.String(), "bag[9]\n  0x03\n"))
+
+	assert.NotNil(t, set([]string{"-path", "[2]", input}, nil, &out))
+	assert.NotNil(t, set([]string{"-path", "bag[2]", "-data", "bag[3]", input}, nil, &out))
+}
+
+func TestDrop(t *testing.T) {
+	var out bytes.Buffer
+	input := base64.StdEncoding.EncodeToString(testInput())
+	err := drop([]string{"-output", "text", "-path", "bag[5]", input}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "bag[2]\n  bag[2].[0]\n    0x0000000000000037\nbag[5]\n  <trim>\n", out.String())
+
+	out.Reset()
+	err = drop([]string{"-output", "text", "-path", "bag[5]", "-markers", "drop", input}, nil, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "bag[2]\n  bag[2].[0]\n    0x0000000000000037\n", out.String())
+
+	assert.NotNil(t, drop([]string{"-path", "bag[5]", "-markers", "keep", input}, nil, &out))
+}
+
+func TestTrim(t *testing.T) {
+	var out bytes.Buffer
+	input := base64.StdEncoding.EncodeToString(testInput())
+	err := trim([]string{"-output", "hex", "-size", "16", input}, nil, &out)
+	assert.Nil(t, err)
+	trimmed, _ := decode(strings.TrimSpace(out.String()), "hex")
+	assert.True(t, len(trimmed) <= 16)
+	atoms, err := atomlayer.Deserialize(trimmed)
+	assert.Nil(t, err)
+	assert.True(t, atomlayer.IsTrimMarker(atoms[len(atoms)-1]))
+
+	assert.NotNil(t, trim([]string{input}, nil, &out))
+}
+
+func TestMerge(t *testing.T) {
+	var out bytes.Buffer
+	err := merge([]string{"-output", "text"}, strings.NewReader("0x02f802020001\n\n0x02f805020002\n"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "bag[2]\n  0x01\nbag[5]\n  0x02\n", out.String())
+
+	assert.NotNil(t, merge(nil, strings.NewReader(""), &out))
+	assert.NotNil(t, merge([]string{"0x02f802", "!!"}, nil, &out))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// Flags common to all commands that read baggage
+type inputFlags struct {
+	encoding string
+	header   string
+}
+
+func (input *inputFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&input.encoding, "encoding", "auto", "Encoding of the input baggage: auto, base64 or hex")
+	flags.StringVar(&input.header, "header", "Baggage", "If the input contains HTTP header lines, the name of the header containing the baggage")
+}
+
+// Reads baggage from the argument, or from stdin if there is no argument
+func (input *inputFlags) read(args []string, stdin io.Reader) (tracingplane.BaggageContext, error) {
+	serialized, err := input.readSerialized(args, stdin)
+	if err != nil { return tracingplane.BaggageContext{}, err }
+	return tracingplane.Deserialize(serialized)
+}
+
+// Reads the serialized bytes of baggage from the argument, or from stdin if there is no argument, without decoding
+// any atoms
+func (input *inputFlags) readSerialized(args []string, stdin io.Reader) ([]byte, error) {
+	var text string
+	switch len(args) {
+	case 0:
+		bytes, err := io.ReadAll(stdin)
+		if err != nil { return nil, err }
+		text = string(bytes)
+	case 1:
+		text = args[0]
+	default:
+		return nil, fmt.Errorf("Expected at most one baggage argument but got %v", len(args))
+	}
+
+	return input.unwrap(text)
+}
+
+// Reads baggage from each of the arguments, or from each non-empty line of stdin if there are no arguments
+func (input *inputFlags) readAll(args []string, stdin io.Reader) ([]tracingplane.BaggageContext, error) {
+	if len(args) == 0 {
+		bytes, err := io.ReadAll(stdin)
+		if err != nil { return nil, err }
+		for _, line := range strings.Split(string(bytes), "\n") {
+			if strings.TrimSpace(line) != "" { args = append(args, line) }
+		}
+	}
+
+	baggages := make([]tracingplane.BaggageContext, 0, len(args))
+	for i, arg := range args {
+		baggage, err := input.parse(arg)
+		if err != nil { return nil, fmt.Errorf("Baggage %v: %v", i+1, err) }
+		baggages = append(baggages, baggage)
+	}
+	return baggages, nil
+}
+
+func (input *inputFlags) parse(text string) (tracingplane.BaggageContext, error) {
+	serialized, err := input.unwrap(text)
+	if err != nil { return tracingplane.BaggageContext{}, err }
+	return tracingplane.Deserialize(serialized)
+}
+
+// Extracts the serialized baggage from the header value and its text encoding
+func (input *inputFlags) unwrap(text string) ([]byte, error) {
+	text, err := headerValue(text, input.header)
+	if err != nil { return nil, err }
+	return decode(text, input.encoding)
+}
+
+// If the text contains HTTP header lines, returns the value of the named header.  Otherwise, returns the text as-is.
+func headerValue(text string, name string) (string, error) {
+	text = strings.TrimSpace(text)
+	if !strings.Contains(text, ":") { return text, nil }		// Neither base64 nor hex contain colons
+
+	for _, line := range strings.Split(text, "\n") {
+		if key, value, found := strings.Cut(line, ":"); found && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("Input contains header lines but no %v header", name)
+}
+
+// Decodes base64 or hex text.  Auto-detection treats text prefixed with 0x as hex; otherwise it tries hex, then each
+// variant of base64.  Hex comes first because bare hex of even length is usually valid base64 too, and would decode
+// to the wrong bytes.
+func decode(text string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "hex": return hex.DecodeString(strings.TrimPrefix(text, "0x"))
+	case "base64": return base64.StdEncoding.DecodeString(text)
+	case "auto":
+	default: return nil, fmt.Errorf("Unknown encoding %q", encoding)
+	}
+
+	if strings.HasPrefix(text, "0x") { return hex.DecodeString(text[2:]) }
+	if decoded, err := hex.DecodeString(text); err == nil { return decoded, nil }
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(text); err == nil { return decoded, nil }
+	}
+	return nil, fmt.Errorf("Input is neither base64 nor hex")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// Prints the bag tree, the size of each root bag, which bags overflowed, and any validation problems
+func inspect(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	var input inputFlags
+	input.register(flags)
+	asJSON := flags.Bool("json", false, "Print the baggage as JSON, interpreting registered bags as typed values")
+	if err := flags.Parse(args); err != nil { return err }
+
+	// Malformed baggage is salvaged, so that what can be recovered is still shown, and the error is listed as a problem
+	serialized, err := input.readSerialized(flags.Args(), stdin)
+	if err != nil { return err }
+	baggage, report, _ := tracingplane.DeserializeWithOptions(serialized, atomlayer.DecodeOptions{Salvage: true})
+	var problems []error
+	if report.Salvaged {
+		problems = append(problems, fmt.Errorf("Discarded the last %v of %v bytes: %v", report.DiscardedBytes, len(serialized), report.Cause))
+	}
+
+	if *asJSON {
+		if err := writeJSON(stdout, baggage); err != nil { return err }
+		if report.Salvaged { return problems[0] }
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "%v atoms, %v bytes\n\n", len(baggage.Atoms), baggage.SerializedSize())
+	fmt.Fprint(stdout, baggage.String())
+
+	overflowPaths := baggageprotocol.OverflowPaths(baggage.Atoms)
+	_, rootBags := baggageprotocol.SplitRootBags(baggage.Atoms)
+	if len(rootBags) > 0 {
+		fmt.Fprintln(stdout, "\nBags:")
+		for _, bag := range rootBags {
+			name, status := "", ""
+			if index, indexed := bag.Index(); indexed {
+				if bagType, known := tracingplane.LookupBag(index); known { name = bagType.Name }
+			}
+			if overflowedWithin(overflowPaths, bag.Header) { status = "  overflowed" }
+			fmt.Fprintf(stdout, "  %-20v %-12v %6v bytes%v\n", baggageprotocol.FormatPath(bag.Atoms[:1]), name, atomlayer.SerializedSize(bag.Atoms), status)
+		}
+	}
+
+	if len(overflowPaths) > 0 {
+		fmt.Fprintln(stdout, "\nOverflowed:")
+		for _, path := range overflowPaths {
+			switch len(path) {
+			case 0: fmt.Fprintln(stdout, "  (before all bags)")
+			default: fmt.Fprintf(stdout, "  %v\n", baggageprotocol.FormatPath(path))
+			}
+		}
+		if baggageprotocol.MayHaveDroppedBags(baggage.Atoms) { fmt.Fprintln(stdout, "  (bags may have been dropped entirely)") }
+	}
+
+	problems = append(problems, baggageprotocol.Validate(baggage.Atoms)...)
+	if len(problems) > 0 {
+		fmt.Fprintln(stdout, "\nProblems:")
+		for _, problem := range problems { fmt.Fprintf(stdout, "  %v\n", problem) }
+		return fmt.Errorf("Baggage has %v problems", len(problems))
+	}
+	return nil
+}
+
+// Returns true if any of the overflow paths are within the root bag with the provided header
+func overflowedWithin(paths [][]atomlayer.Atom, header atomlayer.Atom) bool {
+	for _, path := range paths {
+		if len(path) > 0 && bytes.Equal(path[0], header) { return true }
+	}
+	return false
+}
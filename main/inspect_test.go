@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+func testInput() []byte {
+	return atomlayer.Serialize([]atomlayer.Atom{
+		{248, 2},
+		{240, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 55},
+		{248, 5},
+		{0, 1},
+		{},
+	})
+}
+
+func TestDecodeInput(t *testing.T) {
+	serialized := testInput()
+	for _, text := range []string{
+		base64.StdEncoding.EncodeToString(serialized),
+		base64.RawURLEncoding.EncodeToString(serialized),
+		"0x" + hex.EncodeToString(serialized),
+	} {
+		decoded, err := decode(text, "auto")
+		assert.Nil(t, err)
+		assert.Equal(t, serialized, decoded)
+	}
+
+	decoded, err := decode(hex.EncodeToString(serialized), "hex")
+	assert.Nil(t, err)
+	assert.Equal(t, serialized, decoded)
+
+	// Bare hex is also valid base64, but is decoded as hex
+	_, err = base64.RawStdEncoding.DecodeString(hex.EncodeToString(serialized))
+	assert.Nil(t, err)
+	decoded, err = decode(hex.EncodeToString(serialized), "auto")
+	assert.Nil(t, err)
+	assert.Equal(t, serialized, decoded)
+
+	_, err = decode("!!", "auto")
+	assert.NotNil(t, err)
+	_, err = decode("AA==", "base32")
+	assert.NotNil(t, err)
+}
+
+func TestHeaderValue(t *testing.T) {
+	value, err := headerValue("  AAEC  \n", "Baggage")
+	assert.Nil(t, err)
+	assert.Equal(t, "AAEC", value)
+
+	value, err = headerValue("GET / HTTP/1.1\r\nHost: example.com\r\nbaggage: AAEC\r\n", "Baggage")
+	assert.Nil(t, err)
+	assert.Equal(t, "AAEC", value)
+
+	_, err = headerValue("Host: example.com", "Baggage")
+	assert.NotNil(t, err)
+}
+
+func TestInspect(t *testing.T) {
+	var out bytes.Buffer
+	err := inspect([]string{base64.StdEncoding.EncodeToString(testInput())}, nil, &out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "6 atoms, 23 bytes")
+	assert.Contains(t, out.String(), "bag[2].[0]")
+	assert.Contains(t, out.String(), "bag[2]               zipkin           16 bytes\n")
+	assert.Contains(t, out.String(), "bag[5]               xtrace            7 bytes  overflowed\n")
+	assert.NotContains(t, out.String(), "Problems")
+
+	out.Reset()
+	err = inspect([]string{"-json"}, strings.NewReader("Baggage: 0x" + hex.EncodeToString(testInput())), &out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), `"value"`)
+
+	// Trimming drops bag 5 and places the trim marker at the end of the baggage, in bag 2
+	atoms, _ := atomlayer.Deserialize(testInput())
+	out.Reset()
+	err = inspect([]string{base64.StdEncoding.EncodeToString(atomlayer.Serialize(atomlayer.Trim(atoms, 17)))}, nil, &out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "Overflowed:\n  bag[2].[0]\n  (bags may have been dropped entirely)\n")
+	assert.NotContains(t, out.String(), "bag[5]")
+
+	// Truncated baggage shows what could be recovered, followed by the decoding error
+	out.Reset()
+	err = inspect([]string{"0x" + hex.EncodeToString(testInput()[:len(testInput())-3])}, nil, &out)
+	assert.NotNil(t, err)
+	assert.Contains(t, out.String(), "bag[2]               zipkin           16 bytes\n")
+	assert.Contains(t, out.String(), "Problems:\n  Discarded the last 1 of 20 bytes: ")
+
+	out.Reset()
+	err = inspect([]string{"0x02f80202f801"}, nil, &out)
+	assert.NotNil(t, err)
+	assert.Contains(t, out.String(), "Problems:")
+}
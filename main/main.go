@@ -1,8 +1,57 @@
 package main
 
-import "fmt"
-import "github.com/tracingplane/tracingplane-go/atomlayer"
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	_ "github.com/tracingplane/tracingplane-go/examples" // Registers the example bag types, so that they can be decoded
+)
+
+// tpctl is a command-line tool for inspecting and manipulating serialized baggage, eg. baggage captured from the
+// headers of an HTTP request.
+//
+// Usage:
+//   tpctl <command> [flags] [baggage]
+//
+// Run tpctl with no arguments for the list of commands.
+
+type command struct {
+	run     func(args []string, stdin io.Reader, stdout io.Writer) error
+	summary string
+}
+
+var commands = map[string]command{
+	"inspect": {inspect, "Print the bags, sizes, overflow and validation problems of baggage"},
+	"build":   {build, "Build baggage from a text or JSON description"},
+	"set":     {set, "Set the data atoms of the bag at a path"},
+	"drop":    {drop, "Drop the bag at a path"},
+	"trim":    {trim, "Trim baggage to a maximum serialized size"},
+	"merge":   {merge, "Merge several baggages"},
+	"diff":    {diff, "Show the atoms that differ between two baggages"},
+}
 
 func main() {
-	fmt.Println("Hello, World..... !")
-}
\ No newline at end of file
+	if len(os.Args) < 2 { usage(os.Stderr); os.Exit(2) }
+
+	cmd, exists := commands[os.Args[1]]
+	if !exists { fmt.Fprintf(os.Stderr, "tpctl: unknown command %q\n", os.Args[1]); usage(os.Stderr); os.Exit(2) }
+
+	if err := cmd.run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "tpctl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tpctl <command> [flags] [baggage]")
+	fmt.Fprintln(w, "Baggage is read from the argument, or from stdin if omitted.  Run tpctl <command> -h for flags.")
+	fmt.Fprintln(w, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands { names = append(names, name) }
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10v %v\n", name, commands[name].summary)
+	}
+}
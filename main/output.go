@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"github.com/tracingplane/tracingplane-go/tracingplane"
+)
+
+// Flags common to all commands that write baggage
+type outputFlags struct {
+	format string
+}
+
+func (output *outputFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&output.format, "output", "base64", "Format of the output baggage: base64, hex, text or json")
+}
+
+// Writes the baggage in the chosen format.  Hex is prefixed with 0x so that it can be read back as input.
+func (output *outputFlags) write(stdout io.Writer, baggage tracingplane.BaggageContext) error {
+	switch output.format {
+	case "base64":	fmt.Fprintln(stdout, tracingplane.EncodeBase64(baggage))
+	case "hex":		fmt.Fprintln(stdout, "0x" + hex.EncodeToString(tracingplane.Serialize(baggage)))
+	case "text":	fmt.Fprint(stdout, baggage.String())
+	case "json":	return writeJSON(stdout, baggage)
+	default:		return fmt.Errorf("Unknown output format %q", output.format)
+	}
+	return nil
+}
+
+// Writes the baggage as indented JSON, interpreting registered bags as typed values
+func writeJSON(stdout io.Writer, baggage tracingplane.BaggageContext) error {
+	encoded, err := tracingplane.MarshalJSONWithSchema(baggage, tracingplane.DefaultRegistry)
+	if err != nil { return err }
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, encoded, "", "  "); err != nil { return err }
+	fmt.Fprintln(stdout, indented.String())
+	return nil
+}
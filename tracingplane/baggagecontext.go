@@ -2,9 +2,13 @@ package tracingplane
 
 import (
 	"encoding/base64"
+	"fmt"
+	"io"
 	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
 	"context"
 	"math/rand"
+	"unsafe"
 )
 
 // This file has the main declaration of the tracing plane's BaggageContext object, and the main API calls for
@@ -15,23 +19,35 @@ import (
 
 // Provides the base declaration of BaggageContext which internally uses the atom layer's atom representation
 type BaggageContext struct {
-	Atoms   []atomlayer.Atom // The underlying Atoms of this baggagecontext
+	Atoms   []atomlayer.Atom // The underlying Atoms of this baggagecontext.  Shared between branches, which is only
+										// safe by convention: never modify the elements of this slice or the bytes of
+										// its atoms in place -- assign a new slice instead
 	Context context.Context  // A golang context carried with this baggagecontext.  Propagates through calls
 										// to branch, but not with all merge calls.
 	componentId **uint32				// A randomly generated ID for this component; only propagates to one side of
 										// branch calls.
+	cache       *bagCache				// Decoded bags, if enabled with EnableCache
+	serialized  []byte					// If non-nil, the baggage is lazy and Atoms have not yet been parsed from
+										// these bytes.  See DeserializeLazy
 }
 
 
 // Returns a new BaggageContext with the contents of A and B merged together.
 // The returned BaggageContext will NOT contain anything from B's golang context -- only A's
 func (a BaggageContext) MergeWith(bs ...BaggageContext) BaggageContext {
-	for _, b := range(bs) {
-		a.Atoms = atomlayer.Merge(a.Atoms, b.Atoms)
-		if !a.hasComponentID() {
-			a.componentId = b.componentId
-			// TODO: If multiple baggages have component IDs, keep all of them for later reuse?
+	if len(bs) > 0 {
+		a.parsePartial()
+		inputs := make([][]atomlayer.Atom, 0, len(bs)+1)
+		inputs = append(inputs, a.Atoms)
+		for _, b := range(bs) {
+			inputs = append(inputs, b.atoms())
+			if !a.hasComponentID() {
+				a.componentId = b.componentId
+				// TODO: If multiple baggages have component IDs, keep all of them for later reuse?
+			}
 		}
+		a.Atoms = atomlayer.MergeAll(inputs...)
+		a.cache = a.cache.reset()
 	}
 
 	// Remove the component ID from whichever input baggage (a or one of the bs) it came from
@@ -45,33 +61,63 @@ func (a BaggageContext) MergeWith(bs ...BaggageContext) BaggageContext {
 	return a
 }
 
-// Derives a new BaggageContext instance that will be passed, for example, to a different goroutine
+// Derives a new BaggageContext instance that will be passed, for example, to a different goroutine.  The new instance
+// shares its atoms with the original, so this takes constant time
 func (a BaggageContext) Branch() (c BaggageContext) {
 	a.Atoms = atomlayer.Branch(a.Atoms)
 	a.componentId = nil
+	a.cache = a.cache.branch()
 	return a
 }
 
 // Returns the serialized size in bytes of this BaggageContext
 func (baggage BaggageContext) SerializedSize() int {
+	if baggage.IsLazy() { return len(baggage.serialized) }
 	return atomlayer.SerializedSize(baggage.Atoms)
 }
 
 // Serializes the Atoms of the BaggageContext.  The serialized representation doesn't include anything from the golang
 // context, or the component ID
 func Serialize(baggage BaggageContext) []byte {
+	if baggage.IsLazy() { return append([]byte(nil), baggage.serialized...) }
 	return atomlayer.Serialize(baggage.Atoms)
 }
 
+// Serializes the Atoms of the BaggageContext, appending them to dst and returning the extended slice.  If dst has
+// sufficient capacity (see SerializedSize) then no allocation takes place.
+func AppendSerialize(dst []byte, baggage BaggageContext) []byte {
+	if baggage.IsLazy() { return append(dst, baggage.serialized...) }
+	return atomlayer.AppendSerialize(dst, baggage.Atoms)
+}
+
+// Serializes the Atoms of the BaggageContext directly to the provided writer.  Implements io.WriterTo
+func (baggage BaggageContext) WriteTo(w io.Writer) (int64, error) {
+	if baggage.IsLazy() { n, err := w.Write(baggage.serialized); return int64(n), err }
+	return atomlayer.WriteTo(w, baggage.Atoms)
+}
+
 // Deserializes a BaggageContext from bytes
 func Deserialize(bytes []byte) (baggage BaggageContext, err error) {
 	baggage.Atoms, err = atomlayer.Deserialize(bytes)
 	return
 }
 
+// Deserializes a BaggageContext from bytes received from an untrusted source, enforcing the provided limits.  See
+// atomlayer.DeserializeWithOptions for details of salvage mode.
+func DeserializeWithOptions(bytes []byte, options atomlayer.DecodeOptions) (baggage BaggageContext, report atomlayer.DecodeReport, err error) {
+	baggage.Atoms, report, err = atomlayer.DeserializeWithOptions(bytes, options)
+	return
+}
+
 // Serializes the provided BaggageContext then base64 encodes it into a string
 func EncodeBase64(baggage BaggageContext) string {
-	return base64.StdEncoding.EncodeToString(Serialize(baggage))
+	encoded := AppendBase64(make([]byte, 0, EncodedLenBase64(baggage)), baggage)
+	return unsafe.String(unsafe.SliceData(encoded), len(encoded))	// The buffer is never written again, so skip the copy
+}
+
+// Returns the length in bytes of the base64 encoding of the provided BaggageContext
+func EncodedLenBase64(baggage BaggageContext) int {
+	return base64.StdEncoding.EncodedLen(baggage.SerializedSize())
 }
 
 // Decodes and deserializes a BaggageContext from the provided base64-encoded string
@@ -84,12 +130,41 @@ func DecodeBase64(encoded string) (BaggageContext, error) {
 	}
 }
 
-// Drop Atoms from the BaggageContext so that it fits into the specified number of bytes
+// Decodes and deserializes a BaggageContext from the provided base64-encoded string, enforcing the provided limits.
+// Oversized input is rejected (or truncated, in salvage mode) before it is base64 decoded.
+func DecodeBase64WithOptions(encoded string, options atomlayer.DecodeOptions) (BaggageContext, atomlayer.DecodeReport, error) {
+	if options.MaxBytes > 0 && decodedLengthBase64(encoded) > options.MaxBytes {
+		switch maxEncodedLength := base64.StdEncoding.EncodedLen(options.MaxBytes); {
+		case !options.Salvage: return BaggageContext{}, atomlayer.DecodeReport{}, exceededMaxEncodedLength(len(encoded), options.MaxBytes)
+		case len(encoded) > maxEncodedLength: encoded = encoded[:maxEncodedLength]
+		}
+	}
+
+	bytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return BaggageContext{}, atomlayer.DecodeReport{}, err
+	} else {
+		return DeserializeWithOptions(bytes, options)
+	}
+}
+
+// Drop Atoms from the BaggageContext so that it fits into the specified number of bytes.  Atoms are dropped from the
+// lexicographic tail; to choose which bags are trimmed, use TrimWithPolicy.  Lazy baggage that already fits is returned
+// unchanged.
 func Trim(baggage BaggageContext, maxSize int) BaggageContext {
+	if baggage.IsLazy() && len(baggage.serialized) <= maxSize { return baggage }
+	baggage.parsePartial()
 	baggage.Atoms = atomlayer.Trim(baggage.Atoms, maxSize)
+	baggage.cache = baggage.cache.reset()
 	return baggage
 }
 
+// Returns a human-readable representation of the Atoms of this BaggageContext, in the text format of
+// baggageprotocol.FormatText
+func (baggage BaggageContext) String() string {
+	return baggageprotocol.FormatText(baggage.atoms())
+}
+
 func (baggage *BaggageContext) hasComponentID() bool {
 	return baggage.componentId != nil && *baggage.componentId != nil
 }
@@ -107,4 +182,17 @@ func newComponentID() **uint32{
 	componentId := rand.Uint32()
 	componentIdAddr := &componentId
 	return &componentIdAddr
-}
\ No newline at end of file
+}
+
+// The exact decoded length of a well-formed padded base64 string
+func decodedLengthBase64(encoded string) int {
+	length := base64.StdEncoding.DecodedLen(len(encoded))
+	for i := len(encoded)-1; i >= 0 && i >= len(encoded)-2 && rune(encoded[i]) == base64.StdPadding; i-- {
+		length--
+	}
+	return length
+}
+
+func exceededMaxEncodedLength(length, max int) error {
+	return fmt.Errorf("Base64-encoded baggage of length %v exceeds the limit of %v bytes", length, max)
+}
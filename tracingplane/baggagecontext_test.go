@@ -4,6 +4,7 @@ import (
 	"testing"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
 )
 
 func TestEmptyBaggage(t *testing.T) {
@@ -92,4 +93,59 @@ func TestComponentId(t *testing.T) {
 	assert.False(t, f.hasComponentID())
 	assert.True(t, h.hasComponentID())
 	assert.Equal(t, uint32(4059586549), **h.componentId)
-}
\ No newline at end of file
+}
+
+func TestDecodeBase64WithOptions(t *testing.T) {
+	var baggage BaggageContext
+	baggage.Atoms = []atomlayer.Atom{{248, 2}, {0, 1, 2, 3}, {248, 3}}
+	encoded := EncodeBase64(baggage)
+
+	decoded, report, err := DecodeBase64WithOptions(encoded, atomlayer.DecodeOptions{MaxBytes: baggage.SerializedSize()})
+	assert.Nil(t, err)
+	assert.False(t, report.Salvaged)
+	assert.Equal(t, baggage.Atoms, decoded.Atoms)
+
+	_, _, err = DecodeBase64WithOptions(encoded, atomlayer.DecodeOptions{MaxBytes: 9})
+	assert.NotNil(t, err)
+
+	decoded, report, err = DecodeBase64WithOptions(encoded, atomlayer.DecodeOptions{MaxBytes: 9, Salvage: true})
+	assert.Nil(t, err)
+	assert.True(t, report.Salvaged)
+	assert.Equal(t, []atomlayer.Atom{{248, 2}, {0, 1, 2, 3}, atomlayer.TrimMarker}, decoded.Atoms)
+}
+
+func TestMergeWithMany(t *testing.T) {
+	var a, b, c BaggageContext
+	a.Atoms = []atomlayer.Atom{{248, 1}, {0, 1}}
+	b.Atoms = []atomlayer.Atom{{248, 1}, {0, 2}}
+	c.Atoms = []atomlayer.Atom{{248, 1}, {0, 1}, {248, 2}, {0, 3}}
+
+	merged := a.MergeWith(b, c)
+	assert.Equal(t, []atomlayer.Atom{{248, 1}, {0, 1}, {0, 2}, {248, 2}, {0, 3}}, merged.Atoms)
+	assert.Equal(t, a.MergeWith(b).MergeWith(c).Atoms, merged.Atoms)
+}
+
+func TestBranchIsConstantTime(t *testing.T) {
+	var a BaggageContext
+	for i := 0; i < 1000; i++ {
+		a.Atoms = append(a.Atoms, atomlayer.Atom{0, byte(i)})
+	}
+
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { a.Branch() }))
+
+	b := a.Branch()
+	c := Trim(b, 10)
+	assert.Equal(t, 1000, len(a.Atoms))
+	assert.Equal(t, 1000, len(b.Atoms))
+	assert.Equal(t, a.Atoms, b.Atoms)
+	assert.True(t, c.SerializedSize() <= 10)
+
+	merged := a.MergeWith(b)
+	assert.Same(t, &a.Atoms[0], &merged.Atoms[0])
+}
+
+func TestString(t *testing.T) {
+	var baggage BaggageContext
+	baggage.Atoms = []atomlayer.Atom{{248, 2}, {240, 0}, {0, 55}, {}}
+	assert.Equal(t, "bag[2]\n  bag[2].[0]\n    \"7\"\n    <trim>\n", baggage.String())
+}
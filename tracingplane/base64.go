@@ -0,0 +1,67 @@
+package tracingplane
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// This file contains the base64 encoding used to propagate a BaggageContext in text-based headers.  Atoms are
+// serialized and base64 encoded in a single pass, straight into the destination buffer.
+
+// Serializes and base64 encodes the provided BaggageContext, appending it to dst and returning the extended slice.  No
+// intermediate serialized buffer is allocated, and if dst has sufficient capacity (see EncodedLenBase64) then no
+// allocation takes place at all.
+func AppendBase64(dst []byte, baggage BaggageContext) []byte {
+	e := base64Appender{dst: grow(dst, EncodedLenBase64(baggage))}
+	if baggage.IsLazy() { e.write(baggage.serialized); return e.flush() }
+	var prefix [binary.MaxVarintLen64]byte
+	for _, atom := range baggage.Atoms {
+		e.write(prefix[:binary.PutUvarint(prefix[:], uint64(len(atom)))])
+		e.write(atom)
+	}
+	return e.flush()
+}
+
+// Base64 encodes bytes as they are written, carrying over up to two bytes between writes
+type base64Appender struct {
+	dst      []byte
+	pending  [3]byte
+	npending int
+}
+
+func (e *base64Appender) write(p []byte) {
+	if e.npending > 0 {
+		n := copy(e.pending[e.npending:], p)
+		e.npending += n
+		p = p[n:]
+		if e.npending < len(e.pending) { return }
+		e.encode(e.pending[:])
+		e.npending = 0
+	}
+
+	whole := len(p) - len(p) % len(e.pending)
+	e.encode(p[:whole])
+	e.npending = copy(e.pending[:], p[whole:])
+}
+
+func (e *base64Appender) flush() []byte {
+	e.encode(e.pending[:e.npending])
+	e.npending = 0
+	return e.dst
+}
+
+func (e *base64Appender) encode(p []byte) {
+	if len(p) == 0 { return }
+	n := len(e.dst)
+	e.dst = grow(e.dst, base64.StdEncoding.EncodedLen(len(p)))
+	e.dst = e.dst[:n+base64.StdEncoding.EncodedLen(len(p))]
+	base64.StdEncoding.Encode(e.dst[n:], p)
+}
+
+// Ensures dst has capacity for at least n more bytes
+func grow(dst []byte, n int) []byte {
+	if cap(dst) - len(dst) >= n { return dst }
+	grown := make([]byte, len(dst), len(dst) + n)
+	copy(grown, dst)
+	return grown
+}
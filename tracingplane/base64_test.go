@@ -0,0 +1,76 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"encoding/base64"
+	"bytes"
+)
+
+func testBaggage() (baggage BaggageContext) {
+	baggage.Atoms = []atomlayer.Atom{
+		{248, 2},
+		{240, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 55},
+		{240, 1},
+		{0, 0, 0, 0, 0, 0, 0, 0, 70},
+		{},
+	}
+	return
+}
+
+func TestAppendBase64(t *testing.T) {
+	baggage := testBaggage()
+	expected := base64.StdEncoding.EncodeToString(Serialize(baggage))
+
+	assert.Equal(t, expected, EncodeBase64(baggage))
+	assert.Equal(t, len(expected), EncodedLenBase64(baggage))
+	assert.Equal(t, "prefix:" + expected, string(AppendBase64([]byte("prefix:"), baggage)))
+
+	// Exercise every length of carried-over bytes
+	for i := 0; i <= len(baggage.Atoms); i++ {
+		var partial BaggageContext
+		partial.Atoms = baggage.Atoms[:i]
+		assert.Equal(t, base64.StdEncoding.EncodeToString(Serialize(partial)), EncodeBase64(partial))
+	}
+
+	decoded, err := DecodeBase64(EncodeBase64(baggage))
+	assert.Nil(t, err)
+	assert.Equal(t, baggage.Atoms, decoded.Atoms)
+}
+
+func TestAppendDoesNotAllocate(t *testing.T) {
+	baggage := testBaggage()
+	buf := make([]byte, 0, 1024)
+
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { AppendBase64(buf[:0], baggage) }))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { AppendSerialize(buf[:0], baggage) }))
+	assert.Equal(t, 1.0, testing.AllocsPerRun(100, func() { EncodeBase64(baggage) }))
+}
+
+func TestWriteTo(t *testing.T) {
+	baggage := testBaggage()
+
+	var buf bytes.Buffer
+	n, err := baggage.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(baggage.SerializedSize()), n)
+	assert.Equal(t, Serialize(baggage), buf.Bytes())
+	assert.Equal(t, Serialize(baggage), AppendSerialize(nil, baggage))
+}
+
+func BenchmarkEncodeBase64(b *testing.B) {
+	baggage := testBaggage()
+	for i := 0; i < b.N; i++ {
+		EncodeBase64(baggage)
+	}
+}
+
+func BenchmarkAppendBase64(b *testing.B) {
+	baggage := testBaggage()
+	buf := make([]byte, 0, 1024)
+	for i := 0; i < b.N; i++ {
+		buf = AppendBase64(buf[:0], baggage)
+	}
+}
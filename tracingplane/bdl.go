@@ -3,7 +3,6 @@ package tracingplane
 import (
 	"github.com/tracingplane/tracingplane-go/bdl"
 	"github.com/tracingplane/tracingplane-go/baggageprotocol"
-	"github.com/tracingplane/tracingplane-go/atomlayer"
 )
 
 // This file contains extra methods for the BaggageContext structs that are used by BDL-generated code to read and
@@ -11,21 +10,33 @@ import (
 
 // Read the specified bag index into the provided bag object
 func (baggage *BaggageContext) ReadBag(bagIndex uint64, bag bdl.Bag) error {
-	reader := baggageprotocol.Open(baggage.Atoms, bagIndex)
+	// Lazy baggage is only decoded as far as the bag, and isn't cached
+	var reader *baggageprotocol.Reader
+	switch {
+	case baggage.IsLazy():										reader = baggageprotocol.OpenSerialized(baggage.serialized, bagIndex)
+	case baggage.cache.get(baggage.Atoms, bagIndex, bag):		return nil
+	default:													reader = baggageprotocol.Open(baggage.Atoms, bagIndex)
+	}
+
 	bag.Read(reader)
 	reader.Close()
 	bag.SetUnprocessedAtoms(reader.Skipped)
+	if reader.Err == nil && !baggage.IsLazy() { baggage.cache.put(baggage.Atoms, bagIndex, bag) }
 	return reader.Err
 }
 
-// Drops the specified bag index from the provided baggage object
+// Drops the specified bag index from the provided baggage object.  Lazy baggage that is malformed is left unchanged.
 func (baggage *BaggageContext) Drop(bagIndex uint64) {
+	if baggage.Parse() != nil { return }
+	before := baggage.Atoms
 	baggage.Atoms = baggageprotocol.Drop(baggage.Atoms, bagIndex, baggageprotocol.PushMarkerDown)
+	baggage.cache.replaced(before, baggage.Atoms, bagIndex)
 }
 
+// Writes the provided bag object to the specified bag index, replacing the existing bag and any overflow markers it
+// contained
 func (baggage *BaggageContext) Set(bagIndex uint64, bag bdl.Bag) error {
-	// Remove the bag from the baggage
-	baggage.Atoms = baggageprotocol.Drop(baggage.Atoms, bagIndex, baggageprotocol.DropMarker)
+	if err := baggage.Parse(); err != nil { return err }
 
 	// Write the new bag
 	writer := baggageprotocol.WriteBag(bagIndex)
@@ -33,7 +44,9 @@ func (baggage *BaggageContext) Set(bagIndex uint64, bag bdl.Bag) error {
 	writer.AddUnprocessedAtoms(bag.GetUnprocessedAtoms())
 	newAtoms, err := writer.Atoms()
 
-	// Merge it back in
-	baggage.Atoms = atomlayer.Merge(baggage.Atoms, newAtoms)
+	// Splice it in place of the old bag
+	before := baggage.Atoms
+	baggage.Atoms = baggageprotocol.Replace(baggage.Atoms, bagIndex, newAtoms)
+	baggage.cache.replaced(before, baggage.Atoms, bagIndex)
 	return err
 }
\ No newline at end of file
@@ -0,0 +1,82 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/bdl"
+	"encoding/binary"
+)
+
+// A bag containing a single counter, for measuring small updates
+type counterBag struct {
+	count   uint64
+	unknown []atomlayer.Atom
+}
+
+func (bag *counterBag) Read(r *baggageprotocol.Reader) {
+	if payload := r.Next(); len(payload) == 8 { bag.count = binary.BigEndian.Uint64(payload) }
+}
+
+func (bag *counterBag) Write(w *baggageprotocol.Writer) {
+	w.Write(binary.BigEndian.AppendUint64(nil, bag.count))
+}
+
+func (bag *counterBag) SetUnprocessedAtoms(atoms []atomlayer.Atom) { bag.unknown = atoms }
+func (bag *counterBag) GetUnprocessedAtoms() []atomlayer.Atom { return bag.unknown }
+
+// Baggage with many root bags, each containing a few data atoms
+func largeBaggage(bags int) (baggage BaggageContext) {
+	for i := 0; i < bags; i++ {
+		baggage.Atoms = append(baggage.Atoms, baggageprotocol.MakeIndexedHeader(0, uint64(i)))
+		for j := 0; j < 10; j++ { baggage.Atoms = append(baggage.Atoms, baggageprotocol.MakeDataAtom([]byte{byte(j)})) }
+	}
+	return
+}
+
+// The previous implementation of Set, which drops the bag then merges the new bag back in
+func setByDropMerge(baggage *BaggageContext, bagIndex uint64, bag bdl.Bag) error {
+	baggage.Atoms = baggageprotocol.Drop(baggage.Atoms, bagIndex, baggageprotocol.DropMarker)
+	writer := baggageprotocol.WriteBag(bagIndex)
+	bag.Write(writer)
+	writer.AddUnprocessedAtoms(bag.GetUnprocessedAtoms())
+	newAtoms, err := writer.Atoms()
+	baggage.Atoms = atomlayer.Merge(baggage.Atoms, newAtoms)
+	return err
+}
+
+func TestSet(t *testing.T) {
+	for _, index := range []uint64{0, 50, 99, 100, 200} {
+		baggage, expected := largeBaggage(100), largeBaggage(100)
+		expected.Atoms = append(expected.Atoms[:len(expected.Atoms):len(expected.Atoms)], atomlayer.TrimMarker)
+		baggage.Atoms = append(baggage.Atoms[:len(baggage.Atoms):len(baggage.Atoms)], atomlayer.TrimMarker)
+
+		assert.Nil(t, setByDropMerge(&expected, index, &counterBag{count: 7}))
+		assert.Nil(t, baggage.Set(index, &counterBag{count: 7}))
+		assert.Equal(t, expected.Atoms, baggage.Atoms)
+
+		var bag counterBag
+		assert.Nil(t, baggage.ReadBag(index, &bag))
+		assert.Equal(t, uint64(7), bag.count)
+	}
+}
+
+func increment(b *testing.B, set func(*BaggageContext, uint64, bdl.Bag) error) {
+	baggage := largeBaggage(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var bag counterBag
+		baggage.ReadBag(50, &bag)
+		bag.count++
+		set(&baggage, 50, &bag)
+	}
+}
+
+func BenchmarkIncrementSplice(b *testing.B) {
+	increment(b, (*BaggageContext).Set)
+}
+
+func BenchmarkIncrementDropMerge(b *testing.B) {
+	increment(b, setByDropMerge)
+}
@@ -0,0 +1,107 @@
+package tracingplane
+
+import (
+	"reflect"
+	"sync"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// Middleware chains often read the same bag several times per request, and each ReadBag walks the atoms again.  A
+// BaggageContext can optionally cache decoded bags, so that repeated reads of an unchanged bag copy the cached bag
+// instead.  Only bags that implement bdl.CloneableBag are cached.
+//
+// Each cached bag records the Atoms slice it was decoded from, and is only used while the BaggageContext still has the
+// same slice.  This is safe even if Atoms are reassigned directly, provided they are never modified in place.
+// Set and Drop, which only change one bag, keep the cached bags for all other indices.  MergeWith, Trim and
+// TrimWithPolicy return a BaggageContext with an empty cache.  Branch gives the new BaggageContext its own copy of the
+// cache, so that branches used by different goroutines don't contend for it.
+
+type bagCache struct {
+	lock    sync.Mutex
+	entries map[uint64]cachedBag
+}
+
+type cachedBag struct {
+	atoms atomsIdentity    // The Atoms the bag was decoded from
+	bag   bdl.CloneableBag // The decoded bag, which is never handed out, only cloned
+}
+
+// Identifies a slice of atoms by its backing array and length
+type atomsIdentity struct {
+	first  *atomlayer.Atom
+	length int
+}
+
+func identify(atoms []atomlayer.Atom) atomsIdentity {
+	if len(atoms) == 0 { return atomsIdentity{} }
+	return atomsIdentity{&atoms[0], len(atoms)}
+}
+
+// Enables caching of decoded bags for this BaggageContext and the BaggageContexts derived from it
+func (baggage *BaggageContext) EnableCache() {
+	if baggage.cache == nil { baggage.cache = newBagCache() }
+}
+
+func newBagCache() *bagCache {
+	return &bagCache{entries: make(map[uint64]cachedBag)}
+}
+
+// If the bag was cached for these atoms, copies the cached bag into bag and returns true
+func (cache *bagCache) get(atoms []atomlayer.Atom, bagIndex uint64, bag bdl.Bag) bool {
+	if cache == nil || !cacheable(bag) { return false }
+
+	cache.lock.Lock()
+	entry, exists := cache.entries[bagIndex]
+	cache.lock.Unlock()
+	if !exists || entry.atoms != identify(atoms) || reflect.TypeOf(entry.bag) != reflect.TypeOf(bag) { return false }
+
+	reflect.ValueOf(bag).Elem().Set(reflect.ValueOf(entry.bag.Clone()).Elem())
+	return true
+}
+
+// Caches a copy of a bag that was decoded from atoms
+func (cache *bagCache) put(atoms []atomlayer.Atom, bagIndex uint64, bag bdl.Bag) {
+	if cache == nil || !cacheable(bag) { return }
+	clone := bag.(bdl.CloneableBag).Clone().(bdl.CloneableBag)
+
+	cache.lock.Lock()
+	cache.entries[bagIndex] = cachedBag{identify(atoms), clone}
+	cache.lock.Unlock()
+}
+
+// Called when a BaggageContext's atoms change from before to after, with only the specified bag changing.  Drops the
+// cached bag, and moves the other bags cached for before to after.
+func (cache *bagCache) replaced(before, after []atomlayer.Atom, bagIndex uint64) {
+	if cache == nil { return }
+	beforeID, afterID := identify(before), identify(after)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	delete(cache.entries, bagIndex)
+	for index, entry := range cache.entries {
+		if entry.atoms == beforeID { entry.atoms = afterID; cache.entries[index] = entry }
+	}
+}
+
+// Returns a new cache with the same cached bags, or nil if caching isn't enabled
+func (cache *bagCache) branch() *bagCache {
+	if cache == nil { return nil }
+	branched := newBagCache()
+	cache.lock.Lock()
+	for index, entry := range cache.entries { branched.entries[index] = entry }
+	cache.lock.Unlock()
+	return branched
+}
+
+// Returns a new empty cache, or nil if caching isn't enabled
+func (cache *bagCache) reset() *bagCache {
+	if cache == nil { return nil }
+	return newBagCache()
+}
+
+// Bags can only be cached if they can be cloned and then copied into a caller's bag through a pointer
+func cacheable(bag bdl.Bag) bool {
+	_, cloneable := bag.(bdl.CloneableBag)
+	return cloneable && reflect.TypeOf(bag).Kind() == reflect.Ptr && !reflect.ValueOf(bag).IsNil()
+}
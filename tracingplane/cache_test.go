@@ -0,0 +1,135 @@
+package tracingplane
+
+import (
+	"sync"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// A cloneable counter bag that counts how many times it is decoded
+type cloneableCounterBag struct {
+	counterBag
+	tags  map[string]bool
+	reads *int
+}
+
+func (bag *cloneableCounterBag) Read(r *baggageprotocol.Reader) {
+	*bag.reads++
+	bag.counterBag.Read(r)
+	bag.tags = map[string]bool{"read": true}
+}
+
+func (bag *cloneableCounterBag) Clone() bdl.Bag {
+	clone := *bag
+	clone.tags = make(map[string]bool, len(bag.tags))
+	for tag := range bag.tags { clone.tags[tag] = true }
+	return &clone
+}
+
+func readCounter(t *testing.T, baggage *BaggageContext, index uint64, reads *int) *cloneableCounterBag {
+	bag := &cloneableCounterBag{reads: reads}
+	assert.Nil(t, baggage.ReadBag(index, bag))
+	return bag
+}
+
+func TestCacheReadBag(t *testing.T) {
+	reads := 0
+	baggage := largeBaggage(10)
+	baggage.Set(3, &counterBag{count: 5})
+
+	// Without the cache, every read decodes
+	readCounter(t, &baggage, 3, &reads)
+	readCounter(t, &baggage, 3, &reads)
+	assert.Equal(t, 2, reads)
+
+	baggage.EnableCache()
+	reads = 0
+	first := readCounter(t, &baggage, 3, &reads)
+	first.tags["mutated"] = true
+	second := readCounter(t, &baggage, 3, &reads)
+	assert.Equal(t, 1, reads)
+	assert.Equal(t, uint64(5), second.count)
+	assert.Equal(t, map[string]bool{"read": true}, second.tags)
+
+	// Bags that aren't cloneable are never cached
+	var plain counterBag
+	assert.Nil(t, baggage.ReadBag(3, &plain))
+	assert.Equal(t, uint64(5), plain.count)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	reads := 0
+	baggage := largeBaggage(10)
+	baggage.EnableCache()
+	readCounter(t, &baggage, 3, &reads)
+	readCounter(t, &baggage, 4, &reads)
+	assert.Equal(t, 2, reads)
+
+	// Set invalidates only the bag that was set
+	baggage.Set(3, &counterBag{count: 9})
+	assert.Equal(t, uint64(9), readCounter(t, &baggage, 3, &reads).count)
+	readCounter(t, &baggage, 4, &reads)
+	assert.Equal(t, 3, reads)
+
+	// Drop likewise
+	baggage.Drop(3)
+	assert.Equal(t, uint64(0), readCounter(t, &baggage, 3, &reads).count)
+	readCounter(t, &baggage, 4, &reads)
+	assert.Equal(t, 4, reads)
+
+	// Reassigning the atoms directly invalidates everything
+	baggage.Atoms = largeBaggage(10).Atoms
+	readCounter(t, &baggage, 4, &reads)
+	assert.Equal(t, 5, reads)
+
+	// MergeWith and Trim return baggage with an empty cache
+	merged := baggage.MergeWith(largeBaggage(12))
+	readCounter(t, &merged, 4, &reads)
+	assert.Equal(t, 6, reads)
+	trimmed := Trim(baggage, 20)
+	readCounter(t, &trimmed, 0, &reads)
+	assert.Equal(t, 7, reads)
+	readCounter(t, &baggage, 4, &reads)
+	assert.Equal(t, 7, reads)
+}
+
+func TestCacheBranch(t *testing.T) {
+	reads := 0
+	baggage := largeBaggage(10)
+	baggage.EnableCache()
+	readCounter(t, &baggage, 4, &reads)
+
+	// The branch starts with the cached bags, but changes to either side don't affect the other
+	branch := baggage.Branch()
+	readCounter(t, &branch, 4, &reads)
+	assert.Equal(t, 1, reads)
+	branch.Set(4, &counterBag{count: 2})
+	assert.Equal(t, uint64(2), readCounter(t, &branch, 4, &reads).count)
+	assert.Equal(t, uint64(0), readCounter(t, &baggage, 4, &reads).count)
+	assert.Equal(t, 2, reads)
+
+	// Branches can be read concurrently, from the cache
+	for i := 0; i < 10; i++ {
+		baggage.Set(uint64(i), &counterBag{count: uint64(i) * 10})
+		readCounter(t, &baggage, uint64(i), &reads)
+	}
+	assert.Equal(t, 12, reads)
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		branch := baggage.Branch()
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for j := 0; j < 100; j++ {
+				bag := cloneableCounterBag{}
+				assert.Nil(t, branch.ReadBag(uint64(j%10), &bag))
+				assert.Equal(t, uint64(j%10) * 10, bag.count)
+				assert.True(t, bag.tags["read"])
+			}
+		}()
+	}
+	wait.Wait()
+	assert.Equal(t, 12, reads)
+}
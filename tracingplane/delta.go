@@ -0,0 +1,37 @@
+package tracingplane
+
+import (
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+// Delta encoding lets a BaggageContext be propagated by sending only what changed.  For example, when a request returns
+// with its baggage, the callee can send Diff(received, current) instead of the entire baggage, and the caller, which
+// still has the baggage it sent, reconstructs the callee's baggage with Patch.
+
+// Computes the changes needed to turn from into to.  Only the Atoms are compared; the golang context and component ID
+// are ignored.
+func Diff(from, to BaggageContext) atomlayer.Delta {
+	return atomlayer.Diff(from.atoms(), to.atoms())
+}
+
+// Applies a delta computed by Diff to the BaggageContext it was computed from.  Returns an error if the delta was
+// computed from different atoms.  The returned BaggageContext keeps the golang context and component ID of baggage.
+func Patch(baggage BaggageContext, delta atomlayer.Delta) (BaggageContext, error) {
+	if err := baggage.Parse(); err != nil { return baggage, err }
+	atoms, err := delta.Apply(baggage.Atoms)
+	if err != nil { return baggage, err }
+	baggage.Atoms = atoms
+	return baggage, nil
+}
+
+// Serializes a delta computed by Diff
+func SerializeDelta(delta atomlayer.Delta) []byte {
+	return atomlayer.SerializeDelta(delta)
+}
+
+// Deserializes a delta, then applies it to the provided BaggageContext
+func DeserializePatch(baggage BaggageContext, serializedDelta []byte) (BaggageContext, error) {
+	delta, err := atomlayer.DeserializeDelta(serializedDelta)
+	if err != nil { return baggage, err }
+	return Patch(baggage, delta)
+}
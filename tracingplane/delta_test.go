@@ -0,0 +1,34 @@
+package tracingplane
+
+import (
+	"encoding/binary"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+func TestDiffAndPatch(t *testing.T) {
+	sent := testBaggage()
+	returned := sent.Branch()
+	returned.Atoms = atomlayer.Merge(returned.Atoms, []atomlayer.Atom{{240, 2}, {0, 5}})
+
+	patched, err := DeserializePatch(sent, SerializeDelta(Diff(sent, returned)))
+	assert.Nil(t, err)
+	assert.Equal(t, returned.Atoms, patched.Atoms)
+
+	// The delta only applies to the baggage it was computed from
+	_, err = Patch(returned, Diff(sent, returned))
+	assert.NotNil(t, err)
+
+	_, err = DeserializePatch(sent, []byte{1, 2})
+	assert.NotNil(t, err)
+}
+
+func TestDeserializePatchMalformed(t *testing.T) {
+	base := testBaggage()
+	serialized := binary.BigEndian.AppendUint64(nil, atomlayer.Fingerprint(base.Atoms))
+	for _, x := range []uint64{1, 1, 1 << 63 - 1, 0} { serialized = binary.AppendUvarint(serialized, x) }
+
+	_, err := DeserializePatch(base, serialized)
+	assert.NotNil(t, err)
+}
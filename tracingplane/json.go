@@ -0,0 +1,245 @@
+package tracingplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// This file contains the JSON representation of a BaggageContext, for log pipelines and admin tools.  Baggage is
+// represented as a tree of bags.  Each bag has an index or a key, a list of data payloads, and a list of child bags:
+//
+//   {"bags": [{"index": 2, "bags": [{"index": 0, "data": ["AAAAAAAAADc=", null]}]}]}
+//
+// Data payloads are base64 encoded, and trim markers appear as null.  If the Schema knows the type of a root bag, the
+// bag is instead represented by the JSON encoding of that type, eg. {"index": 2, "value": {"TraceID": 55}}.  A typed
+// value is only used if the bag round-trips through it exactly; otherwise, eg. if the bag contains fields the type
+// doesn't know about, the bag is represented as data like any other.  Bag types whose fields are unexported should
+// implement json.Marshaler and json.Unmarshaler, as examples.XTraceMetadata does; otherwise they encode as {}.
+//
+// The JSON representation always round-trips exactly.  Atoms that can't be represented as a tree of bags, such as
+// malformed headers, cause the entire baggage to be represented as a flat list of base64-encoded atoms instead.
+
+// Interprets the contents of root bags, so that their JSON representation can be typed values
+type Schema interface {
+	NewBag(index uint64) bdl.Bag // Returns a new instance of the type of the root bag, or nil if the type is unknown
+}
+
+// The Schema used by MarshalJSON and UnmarshalJSON.  If nil, bags are never represented as typed values.
+var DefaultSchema Schema = DefaultRegistry
+
+type jsonBaggage struct {
+	Data  []*[]byte  `json:"data,omitempty"`  // Data atoms preceding the first bag
+	Bags  []*jsonBag `json:"bags,omitempty"`  // Root bags
+	Atoms [][]byte   `json:"atoms,omitempty"` // All atoms, if they can't be represented as bags
+}
+
+type jsonBag struct {
+	Index *uint64         `json:"index,omitempty"`
+	Key   *string         `json:"key,omitempty"`
+	Level *int            `json:"level,omitempty"` // Only present if the bag is not exactly one level below its parent
+	Value json.RawMessage `json:"value,omitempty"` // The typed value of the bag, if the Schema knows its type
+	Data  []*[]byte       `json:"data,omitempty"`
+	Bags  []*jsonBag      `json:"bags,omitempty"`
+}
+
+// Implements json.Marshaler, using DefaultSchema
+func (baggage BaggageContext) MarshalJSON() ([]byte, error) {
+	return MarshalJSONWithSchema(baggage, DefaultSchema)
+}
+
+// Implements json.Unmarshaler, using DefaultSchema.  Only the Atoms of the BaggageContext are replaced.
+func (baggage *BaggageContext) UnmarshalJSON(data []byte) error {
+	decoded, err := UnmarshalJSONWithSchema(data, DefaultSchema)
+	if err == nil { baggage.Atoms, baggage.serialized = decoded.Atoms, nil }
+	return err
+}
+
+// Encodes the BaggageContext as JSON, representing root bags known to the schema as typed values.  The schema may be nil
+func MarshalJSONWithSchema(baggage BaggageContext, schema Schema) ([]byte, error) {
+	baggage.parsePartial()
+	encoded, ok := bagsToJSON(baggage.Atoms)
+	switch {
+	case ok: encoded.addValues(baggage.Atoms, schema)
+	default:
+		encoded.Atoms = make([][]byte, len(baggage.Atoms))
+		for i, atom := range baggage.Atoms { encoded.Atoms[i] = atom }
+	}
+	return json.Marshal(encoded)
+}
+
+// Decodes a BaggageContext from JSON produced by MarshalJSONWithSchema.  The schema must know the type of any bags that
+// are represented as typed values.
+func UnmarshalJSONWithSchema(data []byte, schema Schema) (baggage BaggageContext, err error) {
+	var decoded jsonBaggage
+	if err = json.Unmarshal(data, &decoded); err != nil { return }
+	baggage.Atoms, err = decoded.atoms(schema)
+	return
+}
+
+// Builds the tree of bags.  Returns false if the atoms can't be represented exactly as a tree.
+func bagsToJSON(atoms []atomlayer.Atom) (jsonBaggage, bool) {
+	var root jsonBag
+	stack, levels := []*jsonBag{&root}, []int{-1}
+	for _, atom := range atoms {
+		current := stack[len(stack)-1]
+		switch level, err := baggageprotocol.HeaderLevel(atom); {
+		case len(current.Bags) > 0 && !baggageprotocol.IsHeader(atom):	return jsonBaggage{}, false		// Data following a child bag
+		case atomlayer.IsTrimMarker(atom):								current.Data = append(current.Data, nil)
+		case baggageprotocol.IsData(atom) && atom[0] == 0:				payload := []byte(atom[1:]); current.Data = append(current.Data, &payload)
+		case !baggageprotocol.IsHeader(atom) || err != nil:				return jsonBaggage{}, false
+		default:
+			for levels[len(levels)-1] >= level { stack, levels = stack[:len(stack)-1], levels[:len(levels)-1] }
+			parent := stack[len(stack)-1]
+			bag, ok := headerToJSON(atom, level, levels[len(levels)-1])
+			if !ok { return jsonBaggage{}, false }
+			parent.Bags = append(parent.Bags, bag)
+			stack, levels = append(stack, bag), append(levels, level)
+		}
+	}
+
+	// Make sure nothing was lost, eg. due to bags appearing out of order
+	encoded := jsonBaggage{Data: root.Data, Bags: root.Bags}
+	if reencoded, err := encoded.atoms(nil); err != nil || !equalAtoms(atoms, reencoded) { return jsonBaggage{}, false }
+	return encoded, true
+}
+
+func headerToJSON(header atomlayer.Atom, level, parentLevel int) (*jsonBag, bool) {
+	var bag jsonBag
+	if level != parentLevel+1 { bag.Level = &level }
+	switch {
+	case baggageprotocol.IsIndexedHeader(header):
+		index, err := baggageprotocol.HeaderIndex(header)
+		if err != nil || !bytes.Equal(header, baggageprotocol.MakeIndexedHeader(level, index)) { return nil, false }
+		bag.Index = &index
+	case baggageprotocol.IsKeyedHeader(header) && utf8.Valid(header[1:]):
+		key := string(header[1:])
+		bag.Key = &key
+	default:
+		return nil, false
+	}
+	return &bag, true
+}
+
+// Replaces the contents of root bags known to the schema with their typed values, where doing so is exact
+func (encoded jsonBaggage) addValues(atoms []atomlayer.Atom, schema Schema) {
+	if schema == nil { return }
+	_, rootBags := baggageprotocol.SplitRootBags(atoms)
+	i := 0
+	for _, bag := range encoded.Bags {
+		if bag.Level != nil { continue }		// Not a root bag
+		rootBag := rootBags[i]
+		i++
+
+		if bag.Index == nil { continue }
+		if value, ok := typedValue(rootBag.Atoms, *bag.Index, schema); ok {
+			bag.Value, bag.Data, bag.Bags = value, nil, nil
+		}
+	}
+}
+
+// Returns the JSON encoding of the bag as the type known to the schema, if it round-trips exactly
+func typedValue(atoms []atomlayer.Atom, index uint64, schema Schema) (json.RawMessage, bool) {
+	bag := schema.NewBag(index)
+	if bag == nil { return nil, false }
+
+	reader := baggageprotocol.Open(atoms, index)
+	bag.Read(reader)
+	reader.Close()
+	if reader.Err != nil { return nil, false }
+	bag.SetUnprocessedAtoms(reader.Skipped)
+
+	value, err := json.Marshal(bag)
+	if err != nil { return nil, false }
+	rewritten, err := valueToAtoms(value, index, schema)
+	if err != nil || !equalAtoms(atoms, rewritten) { return nil, false }
+	return value, true
+}
+
+// Returns the atoms of the root bag represented by a typed value
+func valueToAtoms(value json.RawMessage, index uint64, schema Schema) ([]atomlayer.Atom, error) {
+	var bag bdl.Bag
+	if schema != nil { bag = schema.NewBag(index) }
+	if bag == nil { return nil, unknownBagType(index) }
+	if err := json.Unmarshal(value, bag); err != nil { return nil, err }
+
+	writer := baggageprotocol.WriteBag(index)
+	bag.Write(writer)
+	writer.AddUnprocessedAtoms(bag.GetUnprocessedAtoms())
+	return writer.Atoms()
+}
+
+// Converts the tree of bags back into atoms
+func (encoded jsonBaggage) atoms(schema Schema) ([]atomlayer.Atom, error) {
+	if encoded.Atoms != nil {
+		if encoded.Data != nil || encoded.Bags != nil { return nil, mixedJSONRepresentations() }
+		atoms := make([]atomlayer.Atom, len(encoded.Atoms))
+		for i, atom := range encoded.Atoms { atoms[i] = atom }
+		return atoms, nil
+	}
+
+	root := jsonBag{Data: encoded.Data, Bags: encoded.Bags}
+	return root.appendContents(nil, -1, schema)
+}
+
+func (bag *jsonBag) appendAtoms(atoms []atomlayer.Atom, parentLevel int, schema Schema) ([]atomlayer.Atom, error) {
+	level := parentLevel + 1
+	if bag.Level != nil { level = *bag.Level }
+	if level <= parentLevel || level > 15 { return nil, invalidJSONLevel(level, parentLevel) }
+
+	switch {
+	case bag.Index != nil && bag.Key != nil:	return nil, ambiguousJSONBag()
+	case bag.Value != nil && bag.Index != nil && level == 0:
+		if bag.Data != nil || bag.Bags != nil { return nil, mixedJSONRepresentations() }
+		value, err := valueToAtoms(bag.Value, *bag.Index, schema)
+		return append(atoms, value...), err
+	case bag.Value != nil:						return nil, fmt.Errorf("Only indexed root bags can have typed values")
+	case bag.Index != nil:						atoms = append(atoms, baggageprotocol.MakeIndexedHeader(level, *bag.Index))
+	case bag.Key != nil:						atoms = append(atoms, baggageprotocol.MakeKeyedHeader(level, []byte(*bag.Key)))
+	default:									return nil, ambiguousJSONBag()
+	}
+	return bag.appendContents(atoms, level, schema)
+}
+
+func (bag *jsonBag) appendContents(atoms []atomlayer.Atom, level int, schema Schema) ([]atomlayer.Atom, error) {
+	for _, payload := range bag.Data {
+		switch payload {
+		case nil: atoms = append(atoms, atomlayer.TrimMarker)
+		default: atoms = append(atoms, baggageprotocol.MakeDataAtom(*payload))
+		}
+	}
+	var err error
+	for _, child := range bag.Bags {
+		if child == nil { return nil, ambiguousJSONBag() }
+		if atoms, err = child.appendAtoms(atoms, level, schema); err != nil { return nil, err }
+	}
+	return atoms, nil
+}
+
+func equalAtoms(a, b []atomlayer.Atom) bool {
+	if len(a) != len(b) { return false }
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) { return false }
+	}
+	return true
+}
+
+func unknownBagType(index uint64) error {
+	return fmt.Errorf("Bag %v has a typed value but its type is unknown", index)
+}
+
+func mixedJSONRepresentations() error {
+	return fmt.Errorf("Baggage JSON cannot have both a typed value or atoms, and data or bags")
+}
+
+func ambiguousJSONBag() error {
+	return fmt.Errorf("Each bag in baggage JSON must have exactly one of an index or a key")
+}
+
+func invalidJSONLevel(level, parentLevel int) error {
+	return fmt.Errorf("Bag at level %v cannot be nested in a bag at level %v", level, parentLevel)
+}
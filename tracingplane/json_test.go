@@ -0,0 +1,147 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/bdl"
+	"encoding/json"
+)
+
+// A simple bag containing a list of strings, for testing typed values
+type stringsBag struct {
+	Values  []string
+	unknown []atomlayer.Atom
+}
+
+func (bag *stringsBag) Read(r *baggageprotocol.Reader) {
+	for payload := r.Next(); payload != nil; payload = r.Next() {
+		bag.Values = append(bag.Values, string(payload))
+	}
+}
+
+func (bag *stringsBag) Write(w *baggageprotocol.Writer) {
+	for _, value := range bag.Values { w.Write([]byte(value)) }
+}
+
+func (bag *stringsBag) SetUnprocessedAtoms(atoms []atomlayer.Atom) { bag.unknown = atoms }
+func (bag *stringsBag) GetUnprocessedAtoms() []atomlayer.Atom { return bag.unknown }
+
+type testSchema map[uint64]func() bdl.Bag
+
+func (schema testSchema) NewBag(index uint64) bdl.Bag {
+	if constructor, known := schema[index]; known { return constructor() }
+	return nil
+}
+
+var stringsSchema = testSchema{3: func() bdl.Bag { return &stringsBag{} }}
+
+func parseBaggage(t *testing.T, text string) (baggage BaggageContext) {
+	var err error
+	baggage.Atoms, err = baggageprotocol.ParseText(text)
+	assert.Nil(t, err)
+	return
+}
+
+func assertJSONRoundTrip(t *testing.T, baggage BaggageContext, schema Schema) []byte {
+	encoded, err := MarshalJSONWithSchema(baggage, schema)
+	assert.Nil(t, err)
+	decoded, err := UnmarshalJSONWithSchema(encoded, schema)
+	assert.Nil(t, err)
+	assert.Equal(t, baggage.String(), decoded.String())
+	assert.True(t, equalAtoms(baggage.Atoms, decoded.Atoms))
+	return encoded
+}
+
+func TestJSON(t *testing.T) {
+	baggage := parseBaggage(t, `
+		<trim>
+		bag[2]
+		  bag[2].[0]
+		    0x0037
+		    <trim>
+		  bag[2]."key"
+		    0x
+		bag[3]
+		  "a"
+		  "b"
+	`)
+
+	encoded := assertJSONRoundTrip(t, baggage, nil)
+	assert.JSONEq(t, `{"data": [null], "bags": [
+		{"index": 2, "bags": [{"index": 0, "data": ["ADc=", null]}, {"key": "key", "data": [""]}]},
+		{"index": 3, "data": ["YQ==", "Yg=="]}
+	]}`, string(encoded))
+
+	encoded = assertJSONRoundTrip(t, baggage, stringsSchema)
+	assert.JSONEq(t, `{"data": [null], "bags": [
+		{"index": 2, "bags": [{"index": 0, "data": ["ADc=", null]}, {"key": "key", "data": [""]}]},
+		{"index": 3, "value": {"Values": ["a", "b"]}}
+	]}`, string(encoded))
+}
+
+func TestJSONInexactValue(t *testing.T) {
+	// The bag has a child bag that stringsBag doesn't know about, and a trim marker, so it can't be a typed value
+	baggage := parseBaggage(t, `
+		bag[3]
+		  "a"
+		  <trim>
+		  bag[3].[1]
+		    "b"
+	`)
+
+	encoded := assertJSONRoundTrip(t, baggage, stringsSchema)
+	assert.JSONEq(t, `{"bags": [{"index": 3, "data": ["YQ==", null], "bags": [{"index": 1, "data": ["Yg=="]}]}]}`, string(encoded))
+}
+
+func TestJSONLevelJump(t *testing.T) {
+	baggage := parseBaggage(t, `
+		bag[1]
+		  bag[1].[2].[3]
+		    "x"
+		bag[4]
+	`)
+
+	encoded := assertJSONRoundTrip(t, baggage, nil)
+	assert.JSONEq(t, `{"bags": [{"index": 1, "bags": [{"index": 3, "level": 2, "data": ["eA=="]}]}, {"index": 4}]}`, string(encoded))
+}
+
+func TestJSONAtomsFallback(t *testing.T) {
+	// A data atom with an unknown prefix can only be represented as a raw atom
+	var baggage BaggageContext
+	baggage.Atoms = []atomlayer.Atom{{248, 1}, {240, 0}, {248, 0}, {5, 1}, {}}
+
+	encoded := assertJSONRoundTrip(t, baggage, nil)
+	assert.JSONEq(t, `{"atoms": ["+AE=", "8AA=", "+AA=", "BQE=", ""]}`, string(encoded))
+}
+
+func TestJSONMarshaler(t *testing.T) {
+	baggage := parseBaggage(t, "bag[3]\n\"a\"")
+
+	defaultSchema := DefaultSchema
+	DefaultSchema = stringsSchema
+	defer func() { DefaultSchema = defaultSchema }()
+
+	encoded, err := json.Marshal(map[string]BaggageContext{"baggage": baggage})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"baggage": {"bags": [{"index": 3, "value": {"Values": ["a"]}}]}}`, string(encoded))
+
+	var decoded map[string]BaggageContext
+	assert.Nil(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, baggage.Atoms, decoded["baggage"].Atoms)
+}
+
+func TestJSONInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`{"bags": [{"index": 1, "key": "k"}]}`,
+		`{"bags": [{}]}`,
+		`{"bags": [{"index": 1, "level": 0, "bags": [{"index": 1, "level": 0}]}]}`,
+		`{"bags": [{"index": 5, "value": {}}]}`,
+		`{"atoms": [""], "data": [""]}`,
+		`{"bags": [{"index": "x"}]}`,
+	} {
+		_, err := UnmarshalJSONWithSchema([]byte(invalid), stringsSchema)
+		assert.NotNil(t, err, invalid)
+	}
+}
@@ -0,0 +1,62 @@
+package tracingplane
+
+import (
+	"encoding/base64"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+)
+
+// Pass-through proxies forward baggage without ever inspecting it, so splitting it into atoms is wasted work.  A lazy
+// BaggageContext instead keeps the serialized bytes it was received as, and only parses them into Atoms when they are
+// needed:
+//
+//   - Serialize, AppendSerialize, WriteTo and AppendBase64 re-emit the original bytes verbatim
+//   - ReadBag decodes only as far as the bag being read, without parsing the rest
+//   - Branch, and Trim to a size the baggage already fits into, keep the baggage lazy
+//   - Set, Drop, MergeWith, Trim, and anything else that changes the atoms, parses them first
+//
+// If the bytes are malformed, Set and Drop leave the baggage unchanged, while MergeWith and Trim keep only the atoms
+// preceding the error, just as Deserialize would have.
+//
+// The Atoms of a lazy BaggageContext are nil until it is parsed, so code that uses Atoms directly must call Parse first.
+
+// Returns a lazy BaggageContext for the serialized bytes, which are not copied and must not be modified afterwards.
+// Malformed bytes are not detected until the baggage is parsed or a bag is read.
+func DeserializeLazy(bytes []byte) (baggage BaggageContext) {
+	if bytes == nil { bytes = []byte{} }
+	baggage.serialized = bytes
+	return
+}
+
+// Returns true if the BaggageContext is lazy and has not yet been parsed into Atoms
+func (baggage BaggageContext) IsLazy() bool {
+	return baggage.serialized != nil
+}
+
+// Parses the serialized bytes of a lazy BaggageContext into Atoms.  Does nothing if the baggage isn't lazy.  If the bytes
+// are malformed, returns an error and the baggage remains lazy.
+func (baggage *BaggageContext) Parse() error {
+	if baggage.serialized == nil { return nil }
+	atoms, err := atomlayer.Deserialize(baggage.serialized)
+	if err != nil { return err }
+	baggage.Atoms, baggage.serialized = atoms, nil
+	return nil
+}
+
+// Parses lazy baggage, keeping the atoms preceding any error
+func (baggage *BaggageContext) parsePartial() {
+	baggage.Atoms, baggage.serialized = baggage.atoms(), nil
+}
+
+// Returns the Atoms of the baggage, or if it is lazy, the atoms parsed from its bytes up to any error
+func (baggage BaggageContext) atoms() []atomlayer.Atom {
+	if !baggage.IsLazy() { return baggage.Atoms }
+	atoms, _ := atomlayer.Deserialize(baggage.serialized)
+	return atoms
+}
+
+// Decodes a base64-encoded string into a lazy BaggageContext.  Only base64 errors are detected.
+func DecodeBase64Lazy(encoded string) (BaggageContext, error) {
+	bytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil { return BaggageContext{}, err }
+	return DeserializeLazy(bytes), nil
+}
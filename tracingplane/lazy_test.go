@@ -0,0 +1,99 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"bytes"
+	"encoding/base64"
+)
+
+func TestLazyPassThrough(t *testing.T) {
+	// Front-coded bytes would be re-serialized differently if they were parsed
+	atoms := largeBaggage(5).Atoms
+	serialized := atomlayer.SerializeFrontCoded(atoms)
+	baggage := DeserializeLazy(serialized)
+	assert.True(t, baggage.IsLazy())
+	assert.Nil(t, baggage.Atoms)
+
+	assert.Equal(t, serialized, Serialize(baggage))
+	assert.Equal(t, append([]byte{1}, serialized...), AppendSerialize([]byte{1}, baggage))
+	assert.Equal(t, len(serialized), baggage.SerializedSize())
+	assert.Equal(t, base64.StdEncoding.EncodeToString(serialized), EncodeBase64(baggage))
+	var written bytes.Buffer
+	n, err := baggage.WriteTo(&written)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(serialized)), n)
+	assert.Equal(t, serialized, written.Bytes())
+
+	// Operations that don't change the atoms keep the baggage lazy
+	assert.True(t, baggage.Branch().IsLazy())
+	assert.True(t, Trim(baggage, len(serialized)).IsLazy())
+	assert.True(t, baggage.MergeWith().IsLazy())
+	assert.Equal(t, BaggageContext{Atoms: atoms}.String(), baggage.String())
+	assert.True(t, baggage.IsLazy())
+
+	decoded, err := DecodeBase64Lazy(EncodeBase64(baggage))
+	assert.Nil(t, err)
+	assert.Equal(t, serialized, Serialize(decoded))
+	_, err = DecodeBase64Lazy("!!")
+	assert.NotNil(t, err)
+}
+
+func TestLazyReadBag(t *testing.T) {
+	eager := largeBaggage(5)
+	eager.Set(3, &counterBag{count: 4})
+	baggage := DeserializeLazy(Serialize(eager))
+
+	var bag counterBag
+	assert.Nil(t, baggage.ReadBag(3, &bag))
+	assert.Equal(t, uint64(4), bag.count)
+	assert.True(t, baggage.IsLazy())
+	assert.True(t, Has[*typedStringsBag](DeserializeLazy(Serialize(parseBaggage(t, "bag[9]\n\"a\"")))))
+}
+
+func TestLazyModify(t *testing.T) {
+	eager := largeBaggage(5)
+	serialized := Serialize(eager)
+
+	baggage := DeserializeLazy(serialized)
+	assert.Nil(t, baggage.Set(3, &counterBag{count: 4}))
+	assert.False(t, baggage.IsLazy())
+	eager.Set(3, &counterBag{count: 4})
+	assert.Equal(t, eager.Atoms, baggage.Atoms)
+
+	baggage = DeserializeLazy(serialized)
+	baggage.Drop(2)
+	assert.False(t, baggage.IsLazy())
+	assert.Equal(t, atomlayer.Merge(largeBaggage(2).Atoms, largeBaggage(5).Atoms[33:]), baggage.Atoms)
+
+	trimmed := Trim(DeserializeLazy(serialized), 20)
+	assert.False(t, trimmed.IsLazy())
+	assert.True(t, trimmed.SerializedSize() <= 20)
+
+	merged := DeserializeLazy(serialized).MergeWith(DeserializeLazy(Serialize(largeBaggage(7))))
+	assert.False(t, merged.IsLazy())
+	assert.Equal(t, largeBaggage(7).Atoms, merged.Atoms)
+
+	assert.Equal(t, Diff(largeBaggage(5), largeBaggage(7)), Diff(DeserializeLazy(serialized), largeBaggage(7)))
+}
+
+func TestLazyMalformed(t *testing.T) {
+	serialized := []byte{0x02, 0xf8, 0x03, 0x05}
+	baggage := DeserializeLazy(serialized)
+	assert.Equal(t, serialized, Serialize(baggage))
+
+	var bag counterBag
+	assert.NotNil(t, baggage.ReadBag(3, &bag))
+	assert.NotNil(t, baggage.Set(3, &bag))
+	baggage.Drop(3)
+	assert.NotNil(t, baggage.Parse())
+	assert.True(t, baggage.IsLazy())
+	assert.Equal(t, serialized, Serialize(baggage))
+
+	// Operations that can't return an error keep the atoms preceding the error
+	merged := baggage.MergeWith(BaggageContext{})
+	assert.False(t, merged.IsLazy())
+	assert.Equal(t, []atomlayer.Atom{{0xf8, 0x03}}, merged.Atoms)
+	assert.Equal(t, []atomlayer.Atom{{0xf8, 0x03}}, Trim(baggage, 3).Atoms)
+}
@@ -0,0 +1,139 @@
+package tracingplane
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// Root bags are identified only by their index, so a registry records which bag type lives at each index.
+// BDL-generated bag types register themselves when their package is initialized:
+//
+//   func init() { tracingplane.Register(2, "zipkin", func() bdl.Bag { return &ZipkinMetadata{} }) }
+//
+// Two bag types registered at the same index, or with the same name, would silently corrupt each other's baggage, so
+// Register panics, causing the collision to be detected at startup.  Each Go type can also only be registered once, so
+// that the generic accessors Get, Update and Has can find the index of a bag from its type alone.  Tools such as
+// debuggers, JSON encoders and CLIs can then look up bag types to decode known bags generically.  A Registry is a
+// Schema, and DefaultRegistry is the DefaultSchema.
+
+// A bag type registered at a root bag index
+type BagType struct {
+	Index uint64         // The index of the root bag
+	Name  string         // A human-readable name for the bag, eg. zipkin
+	New   func() bdl.Bag // Returns a new, empty instance of the bag type
+}
+
+// Maps root bag indices to bag types.  Safe for concurrent use.
+type Registry struct {
+	lock    sync.RWMutex
+	byIndex map[uint64]BagType
+	byName  map[string]BagType
+	byType  map[reflect.Type]BagType
+}
+
+// The registry used by Register, LookupBag and LookupBagName
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{byIndex: make(map[uint64]BagType), byName: make(map[string]BagType), byType: make(map[reflect.Type]BagType)}
+}
+
+// Registers a bag type at a root bag index.  Panics if the index, name or type is already registered.
+func (registry *Registry) Register(index uint64, name string, constructor func() bdl.Bag) {
+	if err := registry.TryRegister(index, name, constructor); err != nil { panic(err) }
+}
+
+// Registers a bag type at a root bag index, returning an error if the index, name or type is already registered.  The
+// type is determined by calling the constructor once.
+func (registry *Registry) TryRegister(index uint64, name string, constructor func() bdl.Bag) error {
+	if name == "" || constructor == nil { return invalidBagType(index, name) }
+	instance := constructor()
+	if instance == nil { return invalidBagType(index, name) }
+	bagType, goType := BagType{index, name, constructor}, reflect.TypeOf(instance)
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if existing, exists := registry.byIndex[index]; exists { return indexCollision(index, name, existing) }
+	if existing, exists := registry.byName[name]; exists { return nameCollision(index, name, existing) }
+	if existing, exists := registry.byType[goType]; exists { return typeCollision(index, name, goType, existing) }
+
+	registry.byIndex[index] = bagType
+	registry.byName[name] = bagType
+	registry.byType[goType] = bagType
+	return nil
+}
+
+// Returns the bag type registered at the root bag index
+func (registry *Registry) Lookup(index uint64) (BagType, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	bagType, exists := registry.byIndex[index]
+	return bagType, exists
+}
+
+// Returns the bag type registered with the name
+func (registry *Registry) LookupName(name string) (BagType, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	bagType, exists := registry.byName[name]
+	return bagType, exists
+}
+
+// Returns the bag type whose constructor returns values of the Go type t
+func (registry *Registry) LookupType(t reflect.Type) (BagType, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	bagType, exists := registry.byType[t]
+	return bagType, exists
+}
+
+// Returns all registered bag types, in order of index
+func (registry *Registry) Types() []BagType {
+	registry.lock.RLock()
+	types := make([]BagType, 0, len(registry.byIndex))
+	for _, bagType := range registry.byIndex { types = append(types, bagType) }
+	registry.lock.RUnlock()
+
+	sort.Slice(types, func(i, j int) bool { return types[i].Index < types[j].Index })
+	return types
+}
+
+// Implements Schema.  Returns a new instance of the bag type registered at the index, or nil if there isn't one.
+func (registry *Registry) NewBag(index uint64) bdl.Bag {
+	if bagType, exists := registry.Lookup(index); exists { return bagType.New() }
+	return nil
+}
+
+// Registers a bag type with the DefaultRegistry.  Panics if the index, name or type is already registered.
+func Register(index uint64, name string, constructor func() bdl.Bag) {
+	DefaultRegistry.Register(index, name, constructor)
+}
+
+// Returns the bag type registered with the DefaultRegistry at the root bag index
+func LookupBag(index uint64) (BagType, bool) {
+	return DefaultRegistry.Lookup(index)
+}
+
+// Returns the bag type registered with the DefaultRegistry with the name
+func LookupBagName(name string) (BagType, bool) {
+	return DefaultRegistry.LookupName(name)
+}
+
+func invalidBagType(index uint64, name string) error {
+	return fmt.Errorf("Cannot register bag type %q at index %v without a name and constructor", name, index)
+}
+
+func indexCollision(index uint64, name string, existing BagType) error {
+	return fmt.Errorf("Cannot register bag type %q at index %v; index is already registered to %q", name, index, existing.Name)
+}
+
+func nameCollision(index uint64, name string, existing BagType) error {
+	return fmt.Errorf("Cannot register bag type %q at index %v; name is already registered at index %v", name, index, existing.Index)
+}
+
+func typeCollision(index uint64, name string, goType reflect.Type, existing BagType) error {
+	return fmt.Errorf("Cannot register bag type %q at index %v; type %v is already registered as %q", name, index, goType, existing.Name)
+}
@@ -0,0 +1,59 @@
+package tracingplane
+
+import (
+	"reflect"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// A second bag type, since each type can only be registered once
+type moreStringsBag struct {
+	stringsBag
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(3, "strings", func() bdl.Bag { return &stringsBag{} })
+	registry.Register(1, "more", func() bdl.Bag { return &moreStringsBag{} })
+
+	bagType, exists := registry.Lookup(3)
+	assert.True(t, exists)
+	assert.Equal(t, "strings", bagType.Name)
+	bagType, exists = registry.LookupName("more")
+	assert.True(t, exists)
+	assert.Equal(t, uint64(1), bagType.Index)
+	_, exists = registry.Lookup(2)
+	assert.False(t, exists)
+	bagType, exists = registry.LookupType(reflect.TypeOf(&moreStringsBag{}))
+	assert.True(t, exists)
+	assert.Equal(t, "more", bagType.Name)
+
+	assert.Equal(t, &stringsBag{}, registry.NewBag(3))
+	assert.Nil(t, registry.NewBag(2))
+
+	types := registry.Types()
+	assert.Equal(t, 2, len(types))
+	assert.Equal(t, uint64(1), types[0].Index)
+	assert.Equal(t, uint64(3), types[1].Index)
+}
+
+func TestRegistryCollisions(t *testing.T) {
+	registry := NewRegistry()
+	newStrings := func() bdl.Bag { return &stringsBag{} }
+	assert.Nil(t, registry.TryRegister(3, "strings", newStrings))
+	assert.NotNil(t, registry.TryRegister(3, "other", newStrings))
+	assert.NotNil(t, registry.TryRegister(4, "strings", newStrings))
+	assert.NotNil(t, registry.TryRegister(4, "", newStrings))
+	assert.NotNil(t, registry.TryRegister(4, "other", nil))
+	assert.NotNil(t, registry.TryRegister(4, "other", func() bdl.Bag { return nil }))
+	assert.NotNil(t, registry.TryRegister(4, "other", newStrings))
+	assert.Panics(t, func() { registry.Register(3, "other", newStrings) })
+
+	_, exists := registry.Lookup(4)
+	assert.False(t, exists)
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	assert.Equal(t, Schema(DefaultRegistry), DefaultSchema)
+}
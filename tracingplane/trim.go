@@ -0,0 +1,185 @@
+package tracingplane
+
+import (
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"sort"
+)
+
+// This file contains trimming that is aware of the bags in a BaggageContext.  Trim simply cuts atoms from the
+// lexicographic tail, so whichever bag has the highest index is always the first to lose data.  TrimWithPolicy instead
+// lets applications decide which bags are more important, and reports which bags lost data.
+//
+// Each bag that loses data gets its own trim marker, placed directly after the bag's header, so that readers of that
+// bag see that it overflowed.  A bag that loses all of its data is reduced to its header followed by a trim marker.
+// Only if there is not even room for that is the bag removed entirely, in which case a trim marker is placed before
+// the first root bag to indicate that some bags may be missing.  Markers inside bags don't end the baggage, which
+// would mean that bags following it may have been dropped (see baggageprotocol.MayHaveDroppedBags), unless the last
+// bag is reduced to just its header and marker.
+
+// Configures how TrimWithPolicy chooses which bags to trim
+type TrimPolicy struct {
+	Priorities    map[uint64]int // Bags with lower priority are trimmed first.  Unlisted bags have priority 0, and
+	                             // among bags of equal priority, the bag with the highest index is trimmed first
+	Quotas        map[uint64]int // Per-bag limits on serialized size, enforced regardless of the overall size
+	WholeBagsOnly bool           // If true, bags are never partially trimmed; a bag either keeps all of its data or
+	                             // loses all of it
+}
+
+// Reports which bags lost data in TrimWithPolicy
+type TrimReport struct {
+	Trimmed []uint64 // Bags that lost some or all of their data, but whose header and trim marker were retained
+	Dropped []uint64 // Bags that were removed entirely
+}
+
+// Returns true if any bags lost data
+func (report TrimReport) Overflowed() bool {
+	return len(report.Trimmed) > 0 || len(report.Dropped) > 0
+}
+
+// Drop atoms from the BaggageContext so that it fits into the specified number of bytes, choosing which bags to trim
+// according to the provided policy.  Keyed root bags, which BaggageContext itself never writes, have priority 0 and
+// are not included in the report.  Lazy baggage that already fits, and has no quotas, is returned unchanged.
+func TrimWithPolicy(baggage BaggageContext, maxSize int, policy TrimPolicy) (BaggageContext, TrimReport) {
+	if baggage.IsLazy() && len(baggage.serialized) <= maxSize && len(policy.Quotas) == 0 { return baggage, TrimReport{} }
+	baggage.parsePartial()
+	baggage.cache = baggage.cache.reset()
+	preamble, rootBags := baggageprotocol.SplitRootBags(baggage.Atoms)
+
+	// Enforce per-bag quotas
+	bags := make([]trimCandidate, len(rootBags))
+	for i, bag := range rootBags {
+		bags[i] = trimCandidate{RootBag: bag, atoms: bag.Atoms, size: atomlayer.SerializedSize(bag.Atoms)}
+		if quota, hasQuota := policy.Quotas[bags[i].index()]; hasQuota && bags[i].indexed() {
+			bags[i].shrinkTo(quota, policy.WholeBagsOnly)
+		}
+	}
+
+	// First reduce bags, in priority order, to no less than their header and a trim marker.  Then, if that wasn't
+	// enough, remove bags entirely in the same order
+	order := policy.trimOrder(bags)
+	for _, i := range order {
+		if excess := totalSize(preamble, bags) - maxSize; excess > 0 {
+			bags[i].shrinkTo(max(bags[i].size - excess, bags[i].minimumSize()), policy.WholeBagsOnly)
+		}
+	}
+	for _, i := range order {
+		if excess := totalSize(preamble, bags) - maxSize; excess > 0 {
+			bags[i].shrinkTo(0, policy.WholeBagsOnly)
+		}
+	}
+
+	// Reassemble the atoms, along with a report of which bags lost data.  The final Trim only has an effect if the
+	// preamble alone exceeds the budget
+	var report TrimReport
+	if !anyTrimmed(bags) {
+		baggage.Atoms = atomlayer.Trim(baggage.Atoms, maxSize)
+		return baggage, report
+	}
+
+	atoms := append(make([]atomlayer.Atom, 0, len(baggage.Atoms)+1), preamble...)
+	if anyDropped(bags) && !containsTrimMarker(preamble) {
+		atoms = append(atoms, atomlayer.TrimMarker)
+	}
+	for _, bag := range bags {
+		atoms = append(atoms, bag.atoms...)
+		switch {
+		case !bag.trimmed || !bag.indexed():
+		case bag.atoms == nil: report.Dropped = append(report.Dropped, bag.index())
+		default: report.Trimmed = append(report.Trimmed, bag.index())
+		}
+	}
+	baggage.Atoms = atomlayer.Trim(atoms, maxSize)
+	return baggage, report
+}
+
+// A root bag that is being considered for trimming
+type trimCandidate struct {
+	baggageprotocol.RootBag
+	atoms   []atomlayer.Atom // The atoms retained so far; nil if the bag has been removed entirely
+	size    int              // The serialized size of the retained atoms
+	trimmed bool             // True if the bag has lost data
+}
+
+func (bag *trimCandidate) index() uint64 {
+	index, _ := bag.Index()
+	return index
+}
+
+func (bag *trimCandidate) indexed() bool {
+	_, indexed := bag.Index()
+	return indexed
+}
+
+// Returns the size of the bag once reduced to just its header and a trim marker
+func (bag *trimCandidate) minimumSize() int {
+	return atomlayer.SerializedSize([]atomlayer.Atom{bag.Header, atomlayer.TrimMarker})
+}
+
+// Reduces the bag to at most target bytes.  Keeps the header, a trim marker, then as many of the remaining atoms as
+// possible, or just the header and a trim marker if wholeBagsOnly is set.  If not even the header and trim marker fit,
+// removes the bag.
+func (bag *trimCandidate) shrinkTo(target int, wholeBagsOnly bool) {
+	if bag.size <= target { return }
+
+	keep := 1
+	if !wholeBagsOnly { keep = atomlayer.FittingPrefix(bag.atoms, target - atomlayer.SerializedSize([]atomlayer.Atom{atomlayer.TrimMarker})) }
+
+	if keep == 0 || atomlayer.SerializedSize(bag.atoms[:keep]) >= target {
+		bag.atoms = nil
+	} else {
+		atoms := append(make([]atomlayer.Atom, 0, keep+1), bag.atoms[0], atomlayer.TrimMarker)
+		for _, atom := range bag.atoms[1:keep] {
+			if !atomlayer.IsTrimMarker(atom) { atoms = append(atoms, atom) }
+		}
+		bag.atoms = atoms
+	}
+	bag.size = atomlayer.SerializedSize(bag.atoms)
+	bag.trimmed = true
+}
+
+// Returns the order in which to trim bags: ascending by priority, then descending by position
+func (policy TrimPolicy) trimOrder(bags []trimCandidate) []int {
+	priority := func(i int) int {
+		if !bags[i].indexed() { return 0 }
+		return policy.Priorities[bags[i].index()]
+	}
+
+	order := make([]int, len(bags))
+	for i := range order { order[i] = len(bags) - 1 - i }
+	sort.SliceStable(order, func(i, j int) bool { return priority(order[i]) < priority(order[j]) })
+	return order
+}
+
+// Returns the size of the reassembled atoms, including the trim marker that is added if any bag was removed entirely
+func totalSize(preamble []atomlayer.Atom, bags []trimCandidate) int {
+	size := atomlayer.SerializedSize(preamble)
+	if anyDropped(bags) && !containsTrimMarker(preamble) {
+		size += atomlayer.SerializedSize([]atomlayer.Atom{atomlayer.TrimMarker})
+	}
+	for _, bag := range bags {
+		size += bag.size
+	}
+	return size
+}
+
+func anyTrimmed(bags []trimCandidate) bool {
+	for _, bag := range bags {
+		if bag.trimmed { return true }
+	}
+	return false
+}
+
+func anyDropped(bags []trimCandidate) bool {
+	for _, bag := range bags {
+		if bag.trimmed && bag.atoms == nil { return true }
+	}
+	return false
+}
+
+func containsTrimMarker(atoms []atomlayer.Atom) bool {
+	for _, atom := range atoms {
+		if atomlayer.IsTrimMarker(atom) { return true }
+	}
+	return false
+}
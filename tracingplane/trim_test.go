@@ -0,0 +1,136 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/atomlayer"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+)
+
+func bag(index uint64, payloads ...byte) []atomlayer.Atom {
+	atoms := []atomlayer.Atom{baggageprotocol.MakeIndexedHeader(0, index)}
+	for _, payload := range payloads {
+		atoms = append(atoms, baggageprotocol.MakeDataAtom([]byte{payload}))
+	}
+	return atoms
+}
+
+func bags(bags ...[]atomlayer.Atom) (baggage BaggageContext) {
+	for _, atoms := range bags {
+		baggage.Atoms = append(baggage.Atoms, atoms...)
+	}
+	return
+}
+
+// Returns the atoms of a bag with a trim marker following its header
+func withMarker(atoms []atomlayer.Atom) []atomlayer.Atom {
+	return append([]atomlayer.Atom{atoms[0], atomlayer.TrimMarker}, atoms[1:]...)
+}
+
+// Each bag from bag(i, ...) is 3 bytes of header plus 3 bytes per payload
+func TestTrimWithPolicyFits(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+	assert.Equal(t, 18, baggage.SerializedSize())
+
+	trimmed, report := TrimWithPolicy(baggage, 18, TrimPolicy{})
+	assert.Equal(t, baggage.Atoms, trimmed.Atoms)
+	assert.False(t, report.Overflowed())
+}
+
+func TestTrimWithPolicyDefaultTrimsHighestIndex(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+
+	trimmed, report := TrimWithPolicy(baggage, 16, TrimPolicy{})
+	assert.Equal(t, bags(bag(1, 1, 2), withMarker(bag(2, 3))).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{2}, report.Trimmed)
+	assert.Empty(t, report.Dropped)
+	assert.True(t, trimmed.SerializedSize() <= 16)
+}
+
+func TestTrimWithPolicyPriorities(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+	policy := TrimPolicy{Priorities: map[uint64]int{2: 10}}
+
+	trimmed, report := TrimWithPolicy(baggage, 16, policy)
+	assert.Equal(t, bags(withMarker(bag(1, 1)), bag(2, 3, 4)).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{1}, report.Trimmed)
+
+	// Bag 1 is reduced to its header and a marker before bag 2 loses anything
+	trimmed, report = TrimWithPolicy(baggage, 13, policy)
+	assert.Equal(t, bags(withMarker(bag(1)), bag(2, 3, 4)).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{1}, report.Trimmed)
+
+	trimmed, report = TrimWithPolicy(baggage, 12, policy)
+	assert.Equal(t, bags(withMarker(bag(1)), withMarker(bag(2, 3))).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{1, 2}, report.Trimmed)
+}
+
+func TestTrimWithPolicyWholeBagsOnly(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+
+	trimmed, report := TrimWithPolicy(baggage, 16, TrimPolicy{WholeBagsOnly: true})
+	assert.Equal(t, bags(bag(1, 1, 2), withMarker(bag(2))).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{2}, report.Trimmed)
+}
+
+func TestTrimWithPolicyDropsBags(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+
+	// Not enough room for both bags' headers and markers, so bag 2 is removed entirely
+	trimmed, report := TrimWithPolicy(baggage, 7, TrimPolicy{})
+	assert.Equal(t, append([]atomlayer.Atom{atomlayer.TrimMarker}, withMarker(bag(1))...), trimmed.Atoms)
+	assert.Equal(t, []uint64{1}, report.Trimmed)
+	assert.Equal(t, []uint64{2}, report.Dropped)
+	assert.True(t, trimmed.SerializedSize() <= 7)
+
+	trimmed, report = TrimWithPolicy(baggage, 3, TrimPolicy{})
+	assert.Equal(t, []atomlayer.Atom{atomlayer.TrimMarker}, trimmed.Atoms)
+	assert.Equal(t, []uint64{1, 2}, report.Dropped)
+}
+
+func TestTrimWithPolicyQuotas(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+
+	trimmed, report := TrimWithPolicy(baggage, 100, TrimPolicy{Quotas: map[uint64]int{1: 7}})
+	assert.Equal(t, bags(withMarker(bag(1, 1)), bag(2, 3, 4)).Atoms, trimmed.Atoms)
+	assert.Equal(t, []uint64{1}, report.Trimmed)
+
+	trimmed, report = TrimWithPolicy(baggage, 100, TrimPolicy{Quotas: map[uint64]int{1: 7}, WholeBagsOnly: true})
+	assert.Equal(t, bags(withMarker(bag(1)), bag(2, 3, 4)).Atoms, trimmed.Atoms)
+
+	trimmed, report = TrimWithPolicy(baggage, 100, TrimPolicy{Quotas: map[uint64]int{1: 2}})
+	assert.Equal(t, append([]atomlayer.Atom{atomlayer.TrimMarker}, bag(2, 3, 4)...), trimmed.Atoms)
+	assert.Equal(t, []uint64{1}, report.Dropped)
+}
+
+func TestTrimWithPolicyMarkerIsVisibleToReaders(t *testing.T) {
+	baggage := bags(bag(1, 1, 2), bag(2, 3, 4))
+	trimmed, _ := TrimWithPolicy(baggage, 13, TrimPolicy{Priorities: map[uint64]int{2: 10}})
+
+	r := baggageprotocol.Open(trimmed.Atoms, 1)
+	r.Close()
+	assert.True(t, r.Overflowed)
+}
+
+func TestTrimWithPolicyPartialTrimDropsNothing(t *testing.T) {
+	baggage := bags(bag(2, 1, 2), bag(4, 3, 4))
+
+	// Bag 4 loses data, but no bag is removed, so bags that were never present don't read as overflowed
+	trimmed, report := TrimWithPolicy(baggage, 16, TrimPolicy{})
+	assert.Equal(t, bags(bag(2, 1, 2), withMarker(bag(4, 3))).Atoms, trimmed.Atoms)
+	assert.Empty(t, report.Dropped)
+	assert.False(t, baggageprotocol.MayHaveDroppedBags(trimmed.Atoms))
+	for _, index := range []uint64{1, 3, 5} {
+		assert.False(t, baggageprotocol.Open(trimmed.Atoms, index).Overflowed, "bag %v", index)
+		assert.False(t, baggageprotocol.OpenSerialized(Serialize(trimmed), index).Overflowed, "bag %v", index)
+	}
+	assert.True(t, baggageprotocol.Open(trimmed.Atoms, 4).Overflowed)
+	assert.False(t, baggageprotocol.Open(trimmed.Atoms, 2).Overflowed)
+
+	// The same holds for a bag in the middle of the baggage
+	trimmed, _ = TrimWithPolicy(baggage, 16, TrimPolicy{Priorities: map[uint64]int{4: 10}})
+	assert.Equal(t, bags(withMarker(bag(2, 1)), bag(4, 3, 4)).Atoms, trimmed.Atoms)
+	for _, index := range []uint64{1, 3, 5} {
+		assert.False(t, baggageprotocol.Open(trimmed.Atoms, index).Overflowed, "bag %v", index)
+	}
+}
@@ -0,0 +1,53 @@
+package tracingplane
+
+import (
+	"fmt"
+	"reflect"
+	"github.com/tracingplane/tracingplane-go/baggageprotocol"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// This file contains generic accessors for bags whose types are registered with the DefaultRegistry.  The index of the
+// bag is looked up from its type, so application code never handles raw indices:
+//
+//   zmd, err := tracingplane.Get[*examples.ZipkinMetadata](&baggage)
+//
+//   err := tracingplane.Update(&baggage, func(zmd *examples.ZipkinMetadata) { zmd.SetSampled(true) })
+
+// Reads the bag of type B.  If the baggage does not contain the bag, returns an empty bag.  Returns an error if B is
+// not registered.
+func Get[B bdl.Bag](baggage *BaggageContext) (B, error) {
+	bagType, err := registeredType[B]()
+	if err != nil { var none B; return none, err }
+	bag := bagType.New().(B)
+	return bag, baggage.ReadBag(bagType.Index, bag)
+}
+
+// Reads the bag of type B, passes it to update, then writes it back to the baggage.  If reading the bag fails, update
+// is not called and the baggage is unchanged.
+func Update[B bdl.Bag](baggage *BaggageContext, update func(B)) error {
+	bagType, err := registeredType[B]()
+	if err != nil { return err }
+	bag := bagType.New().(B)
+	if err := baggage.ReadBag(bagType.Index, bag); err != nil { return err }
+	update(bag)
+	return baggage.Set(bagType.Index, bag)
+}
+
+// Returns true if the baggage contains the bag of type B.  Returns false if B is not registered.
+func Has[B bdl.Bag](baggage BaggageContext) bool {
+	bagType, err := registeredType[B]()
+	return err == nil && baggageprotocol.HasBag(baggage.atoms(), bagType.Index)
+}
+
+// Returns the bag type registered with the DefaultRegistry for bags of type B
+func registeredType[B bdl.Bag]() (BagType, error) {
+	goType := reflect.TypeOf((*B)(nil)).Elem()
+	bagType, exists := DefaultRegistry.LookupType(goType)
+	if !exists { return BagType{}, unregisteredType(goType) }
+	return bagType, nil
+}
+
+func unregisteredType(goType reflect.Type) error {
+	return fmt.Errorf("Bag type %v is not registered", goType)
+}
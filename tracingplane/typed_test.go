@@ -0,0 +1,53 @@
+package tracingplane
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracingplane/tracingplane-go/bdl"
+)
+
+// Registered only with the DefaultRegistry, for testing the generic accessors
+type typedStringsBag struct {
+	stringsBag
+}
+
+func init() {
+	Register(9, "typed-strings", func() bdl.Bag { return &typedStringsBag{} })
+}
+
+func TestGet(t *testing.T) {
+	baggage := parseBaggage(t, "bag[9]\n\"a\"\n\"b\"")
+	bag, err := Get[*typedStringsBag](&baggage)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, bag.Values)
+
+	var empty BaggageContext
+	bag, err = Get[*typedStringsBag](&empty)
+	assert.Nil(t, err)
+	assert.Empty(t, bag.Values)
+
+	_, err = Get[*stringsBag](&baggage)
+	assert.NotNil(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	var baggage BaggageContext
+	err := Update(&baggage, func(bag *typedStringsBag) { bag.Values = append(bag.Values, "a") })
+	assert.Nil(t, err)
+	err = Update(&baggage, func(bag *typedStringsBag) { bag.Values = append(bag.Values, "b") })
+	assert.Nil(t, err)
+	assert.Equal(t, parseBaggage(t, "bag[9]\n\"a\"\n\"b\"").Atoms, baggage.Atoms)
+
+	called := false
+	err = Update(&baggage, func(bag *stringsBag) { called = true })
+	assert.NotNil(t, err)
+	assert.False(t, called)
+}
+
+func TestHas(t *testing.T) {
+	var baggage BaggageContext
+	assert.False(t, Has[*typedStringsBag](baggage))
+	baggage = parseBaggage(t, "bag[9]\n\"a\"")
+	assert.True(t, Has[*typedStringsBag](baggage))
+	assert.False(t, Has[*stringsBag](baggage))
+}